@@ -1,11 +1,13 @@
 package main
 
 import (
+	"os"
 	"time"
 
 	"go.uber.org/fx"
 
 	"github.com/yurykabanov/backuper/internal/configfx"
+	"github.com/yurykabanov/backuper/internal/discoveryfx"
 	"github.com/yurykabanov/backuper/internal/dockerfx"
 	"github.com/yurykabanov/backuper/internal/domainfx"
 	"github.com/yurykabanov/backuper/internal/loggerfx"
@@ -16,6 +18,20 @@ import (
 func main() {
 	logger := loggerfx.Logger()
 
+	mode := fx.Options(
+		metricsfx.Module,
+		// Discovered rules must be registered before backup manager starts
+		discoveryfx.Module,
+		domainfx.Module,
+	)
+
+	var restoreStatus *domainfx.RestoreStatus
+
+	// Restore single backup instead of running scheduler and HTTP server
+	if len(os.Args) > 1 && os.Args[1] == "restore" {
+		mode = fx.Options(domainfx.RestoreModule, fx.Populate(&restoreStatus))
+	}
+
 	app := fx.New(
 		fx.StartTimeout(15*time.Second),
 		fx.StopTimeout(15*time.Second),
@@ -26,9 +42,12 @@ func main() {
 		configfx.Module,
 		sqlfx.Module,
 		dockerfx.Module,
-		metricsfx.Module,
-		domainfx.Module,
+		mode,
 	)
 
 	app.Run()
+
+	if restoreStatus != nil && restoreStatus.Err() != nil {
+		os.Exit(1)
+	}
 }
@@ -1,27 +1,34 @@
 module github.com/yurykabanov/backuper
 
 require (
+	filippo.io/age v1.0.0
 	github.com/BurntSushi/toml v0.3.1 // indirect
 	github.com/docker/distribution v2.7.1+incompatible
 	github.com/docker/docker v1.13.1
 	github.com/golang-migrate/migrate/v4 v4.2.4
 	github.com/gorilla/mux v1.6.2
 	github.com/jmoiron/sqlx v1.2.0
+	github.com/klauspost/compress v1.10.10
 	github.com/mattn/go-sqlite3 v1.9.0
+	github.com/minio/minio-go v6.0.14+incompatible
+	github.com/mitchellh/go-homedir v1.1.0 // indirect
+	github.com/mitchellh/mapstructure v1.1.2
 	github.com/pkg/errors v0.8.1
+	github.com/pkg/sftp v1.10.1
 	github.com/robfig/cron v0.0.0-20180505203441-b41be1df6967
 	github.com/sirupsen/logrus v1.3.0
 	github.com/spf13/pflag v1.0.3
 	github.com/spf13/viper v1.3.1
-	github.com/stretchr/testify v1.3.0
+	github.com/stretchr/testify v1.4.0
 	github.com/yurykabanov/go-yandex-disk v0.0.0-20190410184150-7d6fc1ff945c
 	go.uber.org/atomic v1.3.2 // indirect
 	go.uber.org/dig v1.7.0 // indirect
 	go.uber.org/fx v1.9.0
 	go.uber.org/goleak v0.10.0 // indirect
 	go.uber.org/multierr v1.1.0 // indirect
-	golang.org/x/net v0.0.0-20190125091013-d26f9f9a57f3 // indirect
-	golang.org/x/sys v0.0.0-20190204203706-41f3e6584952 // indirect
+	golang.org/x/crypto v0.0.0-20210817164053-32db794688a5
+	golang.org/x/net v0.0.0-20210226172049-e18ecbb05110
+	golang.org/x/sys v0.0.0-20210903071746-97244b99971b // indirect
 )
 
 replace (
@@ -1,6 +1,7 @@
 package configfx
 
 import (
+	"fmt"
 	"os"
 
 	"github.com/spf13/pflag"
@@ -9,8 +10,18 @@ import (
 func PFlags() *pflag.FlagSet {
 	fs := pflag.NewFlagSet(os.Args[0], pflag.ExitOnError)
 
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage:\n")
+		fmt.Fprintf(os.Stderr, "  %s [flags]                       run backup scheduler\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s restore <backup id> [flags]   restore backup and exit\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Flags:\n%s", fs.FlagUsages())
+	}
+
 	// Config file flag
 	fs.StringP("config", "c", "", "Config file")
 
+	// Parse errors are handled by flag set itself
+	_ = fs.Parse(os.Args[1:])
+
 	return fs
 }
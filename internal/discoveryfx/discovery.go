@@ -0,0 +1,151 @@
+package discoveryfx
+
+import (
+	"context"
+	"sync"
+
+	docker "github.com/docker/docker/client"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"go.uber.org/fx"
+
+	"github.com/yurykabanov/backuper/internal/domainfx"
+	"github.com/yurykabanov/backuper/pkg/discovery"
+	"github.com/yurykabanov/backuper/pkg/domain"
+	"github.com/yurykabanov/backuper/pkg/encryption"
+)
+
+const (
+	ConfigDiscoveryEnabled = "discovery.enabled"
+)
+
+type DiscoveryConfig struct {
+	Enabled bool
+}
+
+func DiscoveryConfigProvider(v *viper.Viper) *DiscoveryConfig {
+	return &DiscoveryConfig{
+		Enabled: v.GetBool(ConfigDiscoveryEnabled),
+	}
+}
+
+// Registers discovered rules in backup manager configuring encryption
+// of their storages
+type registry struct {
+	manager *domain.BackupManager
+	service *domain.BackupService
+	config  *domainfx.TransferManagerConfig
+
+	// Encrypters set for registered rules, nil if encryption is disabled
+	encrypters   map[string]domain.Encrypter
+	encryptersMu sync.Mutex
+}
+
+func Registry(
+	manager *domain.BackupManager,
+	service *domain.BackupService,
+	config *domainfx.TransferManagerConfig,
+) discovery.Registry {
+	return &registry{
+		manager:    manager,
+		service:    service,
+		config:     config,
+		encrypters: make(map[string]domain.Encrypter),
+	}
+}
+
+func (r *registry) RegisterRule(rule domain.Rule) error {
+	for _, name := range rule.StorageNames() {
+		if _, ok := r.config.NamedEntries[name]; !ok {
+			return errors.Errorf("storage '%s' is not configured", name)
+		}
+	}
+
+	c, err := r.config.RuleEncryption(rule)
+	if err != nil {
+		return err
+	}
+
+	var encrypter domain.Encrypter
+	if c != nil {
+		e, err := encryption.New(*c)
+		if err != nil {
+			return errors.Wrap(err, "Unable to configure encryption")
+		}
+		encrypter = e
+	}
+
+	r.encryptersMu.Lock()
+	defer r.encryptersMu.Unlock()
+
+	// Encrypter is set beforehand, backup could be triggered as soon as rule is registered
+	prev := r.service.SetEncrypter(rule.Name, encrypter)
+
+	err = r.manager.RegisterRule(rule)
+	if err != nil {
+		r.service.SetEncrypter(rule.Name, prev)
+		return err
+	}
+
+	r.encrypters[rule.Name] = encrypter
+
+	return nil
+}
+
+func (r *registry) UnregisterRule(name string) error {
+	r.encryptersMu.Lock()
+	defer r.encryptersMu.Unlock()
+
+	// Taken before the rule is unregistered as it could be registered again right after
+	done := r.manager.Done(name)
+
+	err := r.manager.UnregisterRule(name)
+	if err != nil {
+		return err
+	}
+
+	encrypter := r.encrypters[name]
+	delete(r.encrypters, name)
+
+	// Backups dispatched before the rule was unregistered are still encrypted
+	go func() {
+		<-done
+		r.service.UnsetEncrypter(name, encrypter)
+	}()
+
+	return nil
+}
+
+func Discovery(logger *logrus.Logger, dockerClient *docker.Client, registry discovery.Registry) *discovery.Discovery {
+	return discovery.New(logger, dockerClient, registry)
+}
+
+// RunDiscovery registers rules of already running containers before backup
+// manager starts (so their unfinished backups are resumed) and follows
+// container events afterwards
+func RunDiscovery(lc fx.Lifecycle, config *DiscoveryConfig, d *discovery.Discovery) {
+	if !config.Enabled {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	lc.Append(fx.Hook{
+		OnStart: func(startCtx context.Context) error {
+			err := d.Sync(startCtx)
+			if err != nil {
+				cancel()
+				return errors.Wrap(err, "Unable to discover rules")
+			}
+
+			go d.Watch(ctx)
+
+			return nil
+		},
+		OnStop: func(context.Context) error {
+			cancel()
+			return nil
+		},
+	})
+}
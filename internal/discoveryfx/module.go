@@ -0,0 +1,12 @@
+package discoveryfx
+
+import (
+	"go.uber.org/fx"
+)
+
+var Module = fx.Options(
+	fx.Provide(DiscoveryConfigProvider),
+	fx.Provide(Registry),
+	fx.Provide(Discovery),
+	fx.Invoke(RunDiscovery),
+)
@@ -2,21 +2,38 @@ package domainfx
 
 import (
 	"context"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
 
 	docker "github.com/docker/docker/client"
+	"github.com/minio/minio-go"
+	"github.com/minio/minio-go/pkg/credentials"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
 	"github.com/robfig/cron"
 	"github.com/sirupsen/logrus"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"github.com/yurykabanov/go-yandex-disk"
 	"go.uber.org/fx"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
 
 	"github.com/yurykabanov/backuper/pkg/domain"
+	"github.com/yurykabanov/backuper/pkg/encryption"
 	"github.com/yurykabanov/backuper/pkg/mount"
+	"github.com/yurykabanov/backuper/pkg/registry"
 	"github.com/yurykabanov/backuper/pkg/transfer"
 )
 
 const (
 	ConfigMountTempDirectory = "mount.temp_directory"
+
+	ConfigRegistryDockerConfig = "registry.docker_config"
+	ConfigRegistryCredentials  = "registry.credentials"
 )
 
 func NewCron() *cron.Cron {
@@ -45,6 +62,9 @@ type TransferManagerConfigEntry struct {
 	Type string
 	Root string
 	Opts map[string]interface{}
+
+	// Encryption of archives of rules which don't specify their own
+	Encryption *encryption.Config
 }
 
 func TransferManagerConfigProvider(v *viper.Viper) (*TransferManagerConfig, error) {
@@ -58,23 +78,193 @@ func TransferManagerConfigProvider(v *viper.Viper) (*TransferManagerConfig, erro
 	return &TransferManagerConfig{NamedEntries: config}, nil
 }
 
-func TransferManager(config *TransferManagerConfig) domain.TransferManager {
+type S3TransferOpts struct {
+	Endpoint  string `mapstructure:"endpoint"`
+	Bucket    string `mapstructure:"bucket"`
+	Region    string `mapstructure:"region"`
+	AccessKey string `mapstructure:"access_key"`
+	SecretKey string `mapstructure:"secret_key"`
+	Secure    bool   `mapstructure:"secure"`
+	PathStyle bool   `mapstructure:"path_style"`
+	PartSize  int64  `mapstructure:"part_size"`
+}
+
+type SFTPTransferOpts struct {
+	Address              string `mapstructure:"address"`
+	User                 string `mapstructure:"user"`
+	Password             string `mapstructure:"password"`
+	PrivateKey           string `mapstructure:"private_key"`
+	PrivateKeyPassphrase string `mapstructure:"private_key_passphrase"`
+	KnownHosts           string `mapstructure:"known_hosts"`
+}
+
+type WebDAVTransferOpts struct {
+	Url      string `mapstructure:"url"`
+	Username string `mapstructure:"username"`
+	Password string `mapstructure:"password"`
+}
+
+func TransferManager(config *TransferManagerConfig) (domain.TransferManager, error) {
 	var mounts = make(map[string]domain.TransferManager)
 
 	for k, v := range config.NamedEntries {
 		var m domain.TransferManager
+		var err error
+
 		switch v.Type {
 		case "local":
 			m = transfer.NewLocalMount(v.Root)
 		case "yadisk":
 			m = transfer.NewYaDiskMount(yadisk.NewFromAccessToken(v.Opts["access_token"].(string)), v.Root)
+		case "s3":
+			m, err = newS3Mount(v)
+		case "sftp":
+			m, err = newSFTPMount(v)
+		case "webdav":
+			m, err = newWebDAVMount(v)
 		default:
 			continue
 		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "Unable to configure storage '%s'", k)
+		}
+
 		mounts[k] = m
 	}
 
-	return transfer.NewManager(mounts)
+	return transfer.NewManager(mounts), nil
+}
+
+func newS3Mount(entry TransferManagerConfigEntry) (*transfer.S3Mount, error) {
+	var opts S3TransferOpts
+
+	err := mapstructure.WeakDecode(entry.Opts, &opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Bucket == "" {
+		return nil, errors.New("bucket is not specified")
+	}
+
+	if opts.PartSize != 0 && opts.PartSize < transfer.MinS3PartSize {
+		return nil, errors.Errorf("part_size must be at least %d bytes", transfer.MinS3PartSize)
+	}
+
+	lookup := minio.BucketLookupAuto
+	if opts.PathStyle {
+		lookup = minio.BucketLookupPath
+	}
+
+	client, err := minio.NewWithOptions(opts.Endpoint, &minio.Options{
+		Creds:        credentials.NewStaticV4(opts.AccessKey, opts.SecretKey, ""),
+		Secure:       opts.Secure,
+		Region:       opts.Region,
+		BucketLookup: lookup,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return transfer.NewS3Mount(client, opts.Bucket, entry.Root, opts.PartSize), nil
+}
+
+func newSFTPMount(entry TransferManagerConfigEntry) (*transfer.SFTPMount, error) {
+	var opts SFTPTransferOpts
+
+	err := mapstructure.WeakDecode(entry.Opts, &opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.KnownHosts == "" {
+		return nil, errors.New("known_hosts file is not specified")
+	}
+
+	hostKeyCallback, err := knownhosts.New(opts.KnownHosts)
+	if err != nil {
+		return nil, errors.Wrap(err, "Unable to read known_hosts file")
+	}
+
+	var auth []ssh.AuthMethod
+
+	if opts.PrivateKey != "" {
+		signer, err := loadPrivateKey(opts.PrivateKey, opts.PrivateKeyPassphrase)
+		if err != nil {
+			return nil, errors.Wrap(err, "Unable to load private key")
+		}
+
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+
+	if opts.Password != "" {
+		auth = append(auth, ssh.Password(opts.Password))
+	}
+
+	if len(auth) == 0 {
+		return nil, errors.New("neither password nor private key is specified")
+	}
+
+	config := &ssh.ClientConfig{
+		User:            opts.User,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         30 * time.Second,
+	}
+
+	return transfer.NewSFTPMount(opts.Address, config, entry.Root), nil
+}
+
+func loadPrivateKey(file, passphrase string) (ssh.Signer, error) {
+	key, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if passphrase != "" {
+		return ssh.ParsePrivateKeyWithPassphrase(key, []byte(passphrase))
+	}
+
+	return ssh.ParsePrivateKey(key)
+}
+
+func newWebDAVMount(entry TransferManagerConfigEntry) (*transfer.WebDAVMount, error) {
+	var opts WebDAVTransferOpts
+
+	err := mapstructure.WeakDecode(entry.Opts, &opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Url == "" {
+		return nil, errors.New("url is not specified")
+	}
+
+	return transfer.NewWebDAVMount(&http.Client{}, opts.Url, opts.Username, opts.Password, entry.Root), nil
+}
+
+// RegistryAuth combines credentials from configuration with ones stored in
+// docker config.json, the former take precedence
+func RegistryAuth(v *viper.Viper) (domain.RegistryAuth, error) {
+	var credentials []registry.Credentials
+
+	err := v.UnmarshalKey(ConfigRegistryCredentials, &credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	auth := registry.New(credentials)
+
+	if file := v.GetString(ConfigRegistryDockerConfig); file != "" {
+		dockerConfig, err := registry.LoadDockerConfig(file)
+		if err != nil {
+			return nil, errors.Wrap(err, "Unable to load docker config")
+		}
+
+		auth.Merge(dockerConfig)
+	}
+
+	return auth, nil
 }
 
 func BackupService(
@@ -83,8 +273,59 @@ func BackupService(
 	dockerClient *docker.Client,
 	mountManager domain.MountManager,
 	transferManager domain.TransferManager,
+	encrypters map[string]domain.Encrypter,
+	registryAuth domain.RegistryAuth,
 ) *domain.BackupService {
-	return domain.NewBackupService(logger, repository, dockerClient, mountManager, transferManager)
+	return domain.NewBackupService(logger, repository, dockerClient, mountManager, transferManager, encrypters, registryAuth)
+}
+
+// RuleEncryption returns encryption config of the rule: either its own or the
+// one shared by all its storages. Archive is encrypted once for all storages,
+// so storages with different configs would get copies encrypted for somebody
+// else (or not encrypted at all), such rules are rejected
+func (c *TransferManagerConfig) RuleEncryption(rule domain.Rule) (*encryption.Config, error) {
+	if rule.Encryption != nil {
+		return rule.Encryption, nil
+	}
+
+	storages := rule.StorageNames()
+	first := c.NamedEntries[storages[0]].Encryption
+
+	for _, name := range storages[1:] {
+		if !reflect.DeepEqual(first, c.NamedEntries[name].Encryption) {
+			return nil, errors.Errorf(
+				"Storages '%s' and '%s' of rule '%s' have different encryption, specify encryption of the rule",
+				storages[0], name, rule.Name,
+			)
+		}
+	}
+
+	return first, nil
+}
+
+// Encrypters configures encryption of every rule using either its own
+// or its storages encryption config
+func Encrypters(rules []domain.Rule, config *TransferManagerConfig) (map[string]domain.Encrypter, error) {
+	encrypters := make(map[string]domain.Encrypter)
+
+	for _, rule := range rules {
+		c, err := config.RuleEncryption(rule)
+		if err != nil {
+			return nil, err
+		}
+		if c == nil {
+			continue
+		}
+
+		e, err := encryption.New(*c)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Unable to configure encryption of rule '%s'", rule.Name)
+		}
+
+		encrypters[rule.Name] = e
+	}
+
+	return encrypters, nil
 }
 
 func BackupManager(
@@ -105,3 +346,51 @@ func RunBackupManager(lc fx.Lifecycle, backupManager *domain.BackupManager) {
 		},
 	})
 }
+
+// RestoreStatus holds result of restore, so application could exit with
+// non-zero code after it has been stopped gracefully
+type RestoreStatus struct {
+	err error
+}
+
+func NewRestoreStatus() *RestoreStatus {
+	return &RestoreStatus{}
+}
+
+func (s *RestoreStatus) Err() error {
+	return s.err
+}
+
+// RunRestore restores backup with id passed as `restore <backup id>` command
+// and shuts application down afterwards
+func RunRestore(
+	lc fx.Lifecycle,
+	shutdowner fx.Shutdowner,
+	logger *logrus.Logger,
+	flags *pflag.FlagSet,
+	backupManager *domain.BackupManager,
+	status *RestoreStatus,
+) {
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			id, err := strconv.ParseInt(flags.Arg(1), 10, 64)
+			if err != nil {
+				return errors.Wrapf(err, "Invalid backup id '%s'", flags.Arg(1))
+			}
+
+			go func() {
+				// Status is read once application has been stopped, i.e. after Shutdown
+				status.err = backupManager.Restore(context.Background(), id)
+				if status.err != nil {
+					logger.WithError(status.err).WithField("backup_id", id).Error("Unable to restore backup")
+				} else {
+					logger.WithField("backup_id", id).Info("Backup restored")
+				}
+
+				_ = shutdowner.Shutdown()
+			}()
+
+			return nil
+		},
+	})
+}
@@ -0,0 +1,42 @@
+package domainfx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yurykabanov/backuper/pkg/domain"
+	"github.com/yurykabanov/backuper/pkg/encryption"
+)
+
+func TestTransferManagerConfig_RuleEncryption(t *testing.T) {
+	offsite := &encryption.Config{Type: "age", Recipients: []string{"age1offsite"}}
+
+	config := &TransferManagerConfig{NamedEntries: map[string]TransferManagerConfigEntry{
+		"local_nas":  {Type: "local"},
+		"offsite_s3": {Type: "s3", Encryption: offsite},
+		"backup_s3":  {Type: "s3", Encryption: &encryption.Config{Type: "age", Recipients: []string{"age1offsite"}}},
+	}}
+
+	c, err := config.RuleEncryption(domain.Rule{Name: "some-rule", StorageName: "offsite_s3"})
+	assert.Nil(t, err)
+	assert.Equal(t, offsite, c)
+
+	c, err = config.RuleEncryption(domain.Rule{Name: "some-rule", Storages: []string{"offsite_s3", "backup_s3"}})
+	assert.Nil(t, err)
+	assert.Equal(t, offsite, c)
+
+	// only non-first storage is encrypted
+	_, err = config.RuleEncryption(domain.Rule{Name: "some-rule", Storages: []string{"local_nas", "offsite_s3"}})
+	assert.NotNil(t, err)
+
+	_, err = Encrypters([]domain.Rule{{Name: "some-rule", Storages: []string{"local_nas", "offsite_s3"}}}, config)
+	assert.NotNil(t, err)
+
+	// own encryption of the rule applies to all storages
+	own := &encryption.Config{Type: "age", Recipients: []string{"age1own"}}
+
+	c, err = config.RuleEncryption(domain.Rule{Name: "some-rule", Storages: []string{"local_nas", "offsite_s3"}, Encryption: own})
+	assert.Nil(t, err)
+	assert.Equal(t, own, c)
+}
@@ -4,14 +4,28 @@ import (
 	"go.uber.org/fx"
 )
 
-var Module = fx.Options(
+var providers = fx.Options(
 	fx.Provide(LoadRules),
 	fx.Provide(NewCron),
 	fx.Provide(MountManagerConfigProvider),
 	fx.Provide(MountManager),
 	fx.Provide(TransferManagerConfigProvider),
 	fx.Provide(TransferManager),
+	fx.Provide(Encrypters),
+	fx.Provide(RegistryAuth),
 	fx.Provide(BackupService),
 	fx.Provide(BackupManager),
+)
+
+var Module = fx.Options(
+	providers,
 	fx.Invoke(RunBackupManager),
 )
+
+// RestoreModule restores single backup and stops the application,
+// result of restore is provided as *RestoreStatus
+var RestoreModule = fx.Options(
+	providers,
+	fx.Provide(NewRestoreStatus),
+	fx.Invoke(RunRestore),
+)
@@ -15,5 +15,12 @@ func LoadRules(v *viper.Viper) ([]domain.Rule, error) {
 		return nil, errors.Wrap(err, "Unable to unmarshal rules")
 	}
 
+	for _, rule := range rules {
+		err = rule.Validate()
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid rule '%s'", rule.Name)
+		}
+	}
+
 	return rules, nil
 }
@@ -0,0 +1,39 @@
+package metricsfx
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+
+	"github.com/yurykabanov/backuper/pkg/http/handler"
+	"github.com/yurykabanov/backuper/pkg/http/middleware"
+)
+
+func BackupListHandler(logger *logrus.Logger, repository handler.BackupRepository) *handler.BackupListHandler {
+	return handler.NewBackupListHandler(logger, repository)
+}
+
+func BackupHandler(logger *logrus.Logger, repository handler.BackupRepository) *handler.BackupHandler {
+	return handler.NewBackupHandler(logger, repository)
+}
+
+func BackupLogsHandler(logger *logrus.Logger, repository handler.BackupRepository) *handler.BackupLogsHandler {
+	return handler.NewBackupLogsHandler(logger, repository)
+}
+
+func RegisterBackupHandlers(
+	router *mux.Router,
+	config *HttpServerConfig,
+	list *handler.BackupListHandler,
+	h *handler.BackupHandler,
+	logs *handler.BackupLogsHandler,
+) {
+	router.Handle("/api/backups", list).Methods(http.MethodGet)
+	router.Handle("/api/backups/{id:[0-9]+}", h).Methods(http.MethodGet)
+
+	// Logs could contain sensitive output of dumper containers
+	if config.ApiToken != "" {
+		router.Handle("/api/backups/{id:[0-9]+}/logs", middleware.WithBearerToken(logs, config.ApiToken)).Methods(http.MethodGet)
+	}
+}
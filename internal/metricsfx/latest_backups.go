@@ -10,10 +10,10 @@ import (
 
 func LatestBackupMetricHandler(
 	logger *logrus.Logger,
-	rules []domain.Rule,
+	backupManager *domain.BackupManager,
 	repository handler.BackupRepository,
 ) *handler.BackupMetricHandler {
-	return handler.NewBackupMetricHandler(logger, rules, repository)
+	return handler.NewBackupMetricHandler(logger, backupManager, repository)
 }
 
 func RegisterLatestBackupMetricHandler(router *mux.Router, h *handler.BackupMetricHandler) {
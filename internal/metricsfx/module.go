@@ -13,4 +13,15 @@ var Module = fx.Options(
 
 	fx.Provide(LatestBackupMetricHandler),
 	fx.Invoke(RegisterLatestBackupMetricHandler),
+
+	fx.Provide(PrometheusMetricHandler),
+	fx.Invoke(RegisterPrometheusMetricHandler),
+
+	fx.Provide(BackupListHandler),
+	fx.Provide(BackupHandler),
+	fx.Provide(BackupLogsHandler),
+	fx.Invoke(RegisterBackupHandlers),
+
+	fx.Provide(RuleRunHandler),
+	fx.Invoke(RegisterRuleHandlers),
 )
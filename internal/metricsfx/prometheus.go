@@ -0,0 +1,21 @@
+package metricsfx
+
+import (
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+
+	"github.com/yurykabanov/backuper/pkg/domain"
+	"github.com/yurykabanov/backuper/pkg/http/handler"
+)
+
+func PrometheusMetricHandler(
+	logger *logrus.Logger,
+	backupManager *domain.BackupManager,
+	repository handler.BackupRepository,
+) *handler.PrometheusMetricHandler {
+	return handler.NewPrometheusMetricHandler(logger, backupManager, repository)
+}
+
+func RegisterPrometheusMetricHandler(router *mux.Router, h *handler.PrometheusMetricHandler) {
+	router.Handle("/metrics", h)
+}
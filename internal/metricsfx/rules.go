@@ -0,0 +1,24 @@
+package metricsfx
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+
+	"github.com/yurykabanov/backuper/pkg/domain"
+	"github.com/yurykabanov/backuper/pkg/http/handler"
+	"github.com/yurykabanov/backuper/pkg/http/middleware"
+)
+
+func RuleRunHandler(logger *logrus.Logger, backupManager *domain.BackupManager) *handler.RuleRunHandler {
+	return handler.NewRuleRunHandler(logger, backupManager)
+}
+
+func RegisterRuleHandlers(router *mux.Router, config *HttpServerConfig, run *handler.RuleRunHandler) {
+	if config.ApiToken == "" {
+		return
+	}
+
+	router.Handle("/api/rules/{name}/run", middleware.WithBearerToken(run, config.ApiToken)).Methods(http.MethodPost)
+}
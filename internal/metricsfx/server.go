@@ -20,6 +20,7 @@ const (
 	ConfigServerTimeoutRead  = "server.timeout.read"
 	ConfigServerTimeoutWrite = "server.timeout.write"
 	ConfigServerLogRequests  = "server.log.requests"
+	ConfigServerApiToken     = "server.api.token"
 )
 
 type HttpServerConfig struct {
@@ -27,6 +28,9 @@ type HttpServerConfig struct {
 	ReadTimeout       time.Duration
 	WriteTimeout      time.Duration
 	EnableRequestsLog bool
+	// Token required by endpoints running backups or exposing their logs,
+	// these endpoints are disabled unless it is set
+	ApiToken string
 }
 
 func HttpServerConfigProvider(v *viper.Viper) (*HttpServerConfig, error) {
@@ -35,6 +39,7 @@ func HttpServerConfigProvider(v *viper.Viper) (*HttpServerConfig, error) {
 		ReadTimeout:       v.GetDuration(ConfigServerTimeoutRead),
 		WriteTimeout:      v.GetDuration(ConfigServerTimeoutWrite),
 		EnableRequestsLog: v.GetBool(ConfigServerLogRequests),
+		ApiToken:          v.GetString(ConfigServerApiToken),
 	}, nil
 }
 
@@ -0,0 +1,5 @@
+CREATE TABLE backup_logs
+(
+  backup_id INTEGER NOT NULL PRIMARY KEY REFERENCES backups (id) ON DELETE CASCADE,
+  logs      TEXT    NOT NULL DEFAULT ''
+);
@@ -0,0 +1 @@
+ALTER TABLE backups DROP COLUMN exec_id;
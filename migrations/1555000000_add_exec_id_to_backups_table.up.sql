@@ -0,0 +1 @@
+ALTER TABLE backups ADD COLUMN exec_id VARCHAR(255) NOT NULL DEFAULT '';
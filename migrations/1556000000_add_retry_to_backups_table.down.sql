@@ -0,0 +1,2 @@
+ALTER TABLE backups DROP COLUMN attempt;
+ALTER TABLE backups DROP COLUMN retry_of;
@@ -0,0 +1,2 @@
+ALTER TABLE backups ADD COLUMN retry_of INTEGER NULL REFERENCES backups (id);
+ALTER TABLE backups ADD COLUMN attempt INTEGER NOT NULL DEFAULT 0;
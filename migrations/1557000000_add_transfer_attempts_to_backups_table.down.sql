@@ -0,0 +1 @@
+ALTER TABLE backups DROP COLUMN transfer_attempts;
@@ -0,0 +1 @@
+ALTER TABLE backups ADD COLUMN transfer_attempts INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,15 @@
+CREATE TABLE backup_copies
+(
+  backup_id    INTEGER      NOT NULL REFERENCES backups (id) ON DELETE CASCADE,
+  storage_name VARCHAR(255) NOT NULL,
+  exec_status  INT          NOT NULL,
+  backup_file  VARCHAR(255) NOT NULL DEFAULT '',
+  created_at   TIMESTAMP    NOT NULL,
+  deleted_at   TIMESTAMP    NULL,
+  PRIMARY KEY (backup_id, storage_name)
+);
+
+INSERT INTO backup_copies (backup_id, storage_name, exec_status, backup_file, created_at, deleted_at)
+SELECT id, storage_name, exec_status, backup_file, COALESCE(finished_at, created_at), deleted_at
+FROM backups
+WHERE exec_status = 4 AND backup_file != '';
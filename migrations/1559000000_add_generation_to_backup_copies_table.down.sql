@@ -0,0 +1 @@
+ALTER TABLE backup_copies DROP COLUMN generation;
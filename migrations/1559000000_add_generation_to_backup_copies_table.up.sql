@@ -0,0 +1,4 @@
+ALTER TABLE backup_copies ADD COLUMN generation INTEGER NOT NULL DEFAULT 0;
+
+UPDATE backup_copies
+SET generation = (SELECT generation FROM backups WHERE backups.id = backup_copies.backup_id);
@@ -0,0 +1 @@
+ALTER TABLE backups DROP COLUMN retry_at;
@@ -0,0 +1 @@
+ALTER TABLE backups ADD COLUMN retry_at TIMESTAMP NULL;
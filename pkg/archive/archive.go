@@ -0,0 +1,109 @@
+package archive
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const DefaultFormat = "zip"
+
+// Format packs directory into single archive file and unpacks it back.
+//
+// Regular files (with their modes), directories (including empty ones)
+// and symlinks are preserved, other special files are skipped.
+type Format interface {
+	// Extension of archive files, e.g. ".tar.gz"
+	Extension() string
+
+	// Create packs content of dir into outfile (outfile itself is skipped if it's inside dir)
+	Create(outfile, dir string) error
+
+	// Write packs content of dir into w, skipping file `skip` (if not empty)
+	Write(w io.Writer, dir, skip string) error
+
+	// Extract unpacks archive into dir
+	Extract(archive, dir string) error
+}
+
+var formats = map[string]Format{
+	"zip":     zipFormat{},
+	"tar":     tarFormat{compression: noCompression},
+	"tar.gz":  tarFormat{compression: gzipCompression},
+	"tar.zst": tarFormat{compression: zstdCompression},
+}
+
+// Get returns format by its name: zip, tar, tar.gz or tar.zst
+func Get(name string) (Format, error) {
+	if name == "" {
+		name = DefaultFormat
+	}
+
+	if f, ok := formats[name]; ok {
+		return f, nil
+	}
+
+	return nil, fmt.Errorf("unknown archive format '%s'", name)
+}
+
+// ForFile returns format of archive file by its extension
+func ForFile(name string) (Format, error) {
+	var names []string
+	for n := range formats {
+		names = append(names, n)
+	}
+
+	// longer extensions first, so `.tar.gz` isn't mistaken for `.gz`
+	sort.Slice(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+
+	for _, n := range names {
+		if strings.HasSuffix(name, formats[n].Extension()) {
+			return formats[n], nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown archive format of file '%s'", name)
+}
+
+// Creates outfile and writes archive into it
+func createFile(format Format, outfile, dir string) (err error) {
+	f, err := os.Create(outfile)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if e := f.Close(); e != nil && err == nil {
+			err = e
+		}
+	}()
+
+	return format.Write(f, dir, outfile)
+}
+
+// Walks dir calling fn with path relative to dir for every entry except dir itself and skipped file
+func walk(dir, skip string, fn func(path, rel string, info os.FileInfo) error) error {
+	return filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if p == dir || p == skip {
+			return nil
+		}
+
+		mode := info.Mode()
+		if !mode.IsRegular() && !mode.IsDir() && mode&os.ModeSymlink == 0 {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+
+		return fn(p, filepath.ToSlash(rel), info)
+	})
+}
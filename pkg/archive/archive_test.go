@@ -0,0 +1,189 @@
+package archive
+
+import (
+	"archive/tar"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "backuper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// Creates the following tree:
+//
+//	dump.sql              (0644)
+//	bin/run.sh            (0755)
+//	nested/deeper/x.txt   (0600)
+//	empty/                (0700)
+//	latest.sql -> dump.sql
+func makeTree(t *testing.T, dir string) {
+	must := func(err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	must(ioutil.WriteFile(path.Join(dir, "dump.sql"), []byte("some dump"), 0644))
+	must(os.MkdirAll(path.Join(dir, "bin"), 0755))
+	must(ioutil.WriteFile(path.Join(dir, "bin", "run.sh"), []byte("#!/bin/sh"), 0755))
+	must(os.MkdirAll(path.Join(dir, "nested", "deeper"), 0755))
+	must(ioutil.WriteFile(path.Join(dir, "nested", "deeper", "x.txt"), []byte("x"), 0600))
+	must(os.Mkdir(path.Join(dir, "empty"), 0700))
+	must(os.Symlink("dump.sql", path.Join(dir, "latest.sql")))
+
+	// permissions must not depend on umask
+	must(os.Chmod(path.Join(dir, "bin", "run.sh"), 0755))
+	must(os.Chmod(path.Join(dir, "nested", "deeper", "x.txt"), 0600))
+	must(os.Chmod(path.Join(dir, "empty"), 0700))
+}
+
+func assertTree(t *testing.T, dir string) {
+	data, err := ioutil.ReadFile(path.Join(dir, "dump.sql"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("some dump"), data)
+
+	info, err := os.Stat(path.Join(dir, "bin", "run.sh"))
+	if assert.Nil(t, err) {
+		assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+	}
+
+	// nested directories must not be flattened
+	info, err = os.Stat(path.Join(dir, "nested", "deeper", "x.txt"))
+	if assert.Nil(t, err) {
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	}
+
+	info, err = os.Stat(path.Join(dir, "empty"))
+	if assert.Nil(t, err) {
+		assert.True(t, info.IsDir())
+		assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+	}
+
+	linkname, err := os.Readlink(path.Join(dir, "latest.sql"))
+	assert.Nil(t, err)
+	assert.Equal(t, "dump.sql", linkname)
+}
+
+func TestFormats_CreateExtract(t *testing.T) {
+	for _, name := range []string{"zip", "tar", "tar.gz", "tar.zst"} {
+		t.Run(name, func(t *testing.T) {
+			format, err := Get(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			src := tempDir(t)
+			defer os.RemoveAll(src)
+			dst := tempDir(t)
+			defer os.RemoveAll(dst)
+
+			makeTree(t, src)
+
+			// archive is created inside of directory being archived
+			archive := path.Join(src, "__backup__"+format.Extension())
+
+			err = format.Create(archive, src)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = format.Extract(archive, dst)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assertTree(t, dst)
+
+			_, err = os.Stat(path.Join(dst, "__backup__"+format.Extension()))
+			assert.True(t, os.IsNotExist(err), "archive must not contain itself")
+		})
+	}
+}
+
+func TestTarFormat_Extract_IllegalPath(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	archive := path.Join(dir, "evil.tar")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tw := tar.NewWriter(f)
+	_ = tw.WriteHeader(&tar.Header{Name: "../evil.txt", Mode: 0644, Size: 4, Typeflag: tar.TypeReg})
+	_, _ = tw.Write([]byte("evil"))
+	_ = tw.Close()
+	_ = f.Close()
+
+	err = tarFormat{compression: noCompression}.Extract(archive, path.Join(dir, "out"))
+
+	assert.NotNil(t, err)
+	_, err = os.Stat(path.Join(dir, "evil.txt"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestTarFormat_Extract_ThroughSymlink(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+
+	outside := path.Join(dir, "outside")
+	if err := os.Mkdir(outside, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	archive := path.Join(dir, "evil.tar")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tw := tar.NewWriter(f)
+	_ = tw.WriteHeader(&tar.Header{Name: "link", Linkname: outside, Typeflag: tar.TypeSymlink})
+	_ = tw.WriteHeader(&tar.Header{Name: "link/evil.txt", Mode: 0644, Size: 4, Typeflag: tar.TypeReg})
+	_, _ = tw.Write([]byte("evil"))
+	_ = tw.Close()
+	_ = f.Close()
+
+	_ = tarFormat{compression: noCompression}.Extract(archive, path.Join(dir, "out"))
+
+	_, err = os.Stat(path.Join(outside, "evil.txt"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestGet(t *testing.T) {
+	f, err := Get("")
+	assert.Nil(t, err)
+	assert.Equal(t, ".zip", f.Extension())
+
+	_, err = Get("rar")
+	assert.NotNil(t, err)
+}
+
+func TestForFile(t *testing.T) {
+	cases := map[string]string{
+		"/backups/mysql_2019-01-01_00-00-00.zip":     ".zip",
+		"/backups/mysql_2019-01-01_00-00-00.tar":     ".tar",
+		"/backups/mysql_2019-01-01_00-00-00.tar.gz":  ".tar.gz",
+		"/backups/mysql_2019-01-01_00-00-00.tar.zst": ".tar.zst",
+	}
+
+	for name, ext := range cases {
+		f, err := ForFile(name)
+		if assert.Nil(t, err, name) {
+			assert.Equal(t, ext, f.Extension())
+		}
+	}
+
+	_, err := ForFile("/backups/mysql.rar")
+	assert.NotNil(t, err)
+}
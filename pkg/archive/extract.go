@@ -0,0 +1,157 @@
+package archive
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Extracts entries into directory guarding against paths outside of it.
+//
+// Symlinks are created after all other entries, so entries are never written
+// through symlinks from archive. Directory modes are applied at the very end
+// as well, so read-only directories could be filled in.
+type extractor struct {
+	dir string
+
+	symlinks []extractedSymlink
+	dirs     []extractedDir
+}
+
+type extractedSymlink struct {
+	target, linkname string
+}
+
+type extractedDir struct {
+	target  string
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func newExtractor(dir string) *extractor {
+	return &extractor{dir: filepath.Clean(dir)}
+}
+
+func (e *extractor) target(name string) (string, error) {
+	target := filepath.Join(e.dir, filepath.FromSlash(name))
+
+	// Prevent "zip slip": files must not be extracted outside of target directory
+	if !strings.HasPrefix(target, e.dir+string(os.PathSeparator)) {
+		return "", fmt.Errorf("illegal file path in archive: %s", name)
+	}
+
+	return target, nil
+}
+
+func (e *extractor) mkdir(name string, mode os.FileMode, modTime time.Time) error {
+	target, err := e.target(name)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(target, 0755)
+	if err != nil {
+		return err
+	}
+
+	e.dirs = append(e.dirs, extractedDir{target: target, mode: mode.Perm(), modTime: modTime})
+
+	return nil
+}
+
+func (e *extractor) file(name string, mode os.FileMode, modTime time.Time, r io.Reader) (err error) {
+	target, err := e.target(name)
+	if err != nil {
+		return
+	}
+
+	err = os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return
+	}
+
+	out, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if e := out.Close(); e != nil && err == nil {
+			err = e
+		}
+		if err == nil {
+			err = os.Chtimes(target, modTime, modTime)
+		}
+	}()
+
+	_, err = io.Copy(out, r)
+	if err != nil {
+		return
+	}
+
+	// Mode is set explicitly since umask affects mode passed to OpenFile
+	return out.Chmod(mode.Perm())
+}
+
+func (e *extractor) link(name, linkname string) error {
+	target, err := e.target(name)
+	if err != nil {
+		return err
+	}
+
+	source, err := e.target(linkname)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(target), 0755)
+	if err != nil {
+		return err
+	}
+
+	return os.Link(source, target)
+}
+
+func (e *extractor) symlink(name, linkname string) error {
+	target, err := e.target(name)
+	if err != nil {
+		return err
+	}
+
+	e.symlinks = append(e.symlinks, extractedSymlink{target: target, linkname: linkname})
+
+	return nil
+}
+
+func (e *extractor) finish() error {
+	for _, s := range e.symlinks {
+		err := os.MkdirAll(filepath.Dir(s.target), 0755)
+		if err != nil {
+			return err
+		}
+
+		err = os.Symlink(s.linkname, s.target)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Nested directories go after their parents, so they're processed in reverse order
+	for i := len(e.dirs) - 1; i >= 0; i-- {
+		d := e.dirs[i]
+
+		err := os.Chmod(d.target, d.mode)
+		if err != nil {
+			return err
+		}
+
+		err = os.Chtimes(d.target, d.modTime, d.modTime)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
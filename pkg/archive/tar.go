@@ -0,0 +1,188 @@
+package archive
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+type compression struct {
+	extension string
+	writer    func(io.Writer) (io.WriteCloser, error)
+	reader    func(io.Reader) (io.ReadCloser, error)
+}
+
+var (
+	noCompression = compression{
+		extension: "",
+		writer:    func(w io.Writer) (io.WriteCloser, error) { return nopWriteCloser{w}, nil },
+		reader:    func(r io.Reader) (io.ReadCloser, error) { return ioutil.NopCloser(r), nil },
+	}
+
+	gzipCompression = compression{
+		extension: ".gz",
+		writer:    func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil },
+		reader:    func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+	}
+
+	zstdCompression = compression{
+		extension: ".zst",
+		writer:    func(w io.Writer) (io.WriteCloser, error) { return zstd.NewWriter(w) },
+		reader: func(r io.Reader) (io.ReadCloser, error) {
+			d, err := zstd.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+			return zstdReadCloser{d}, nil
+		},
+	}
+)
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+type zstdReadCloser struct {
+	d *zstd.Decoder
+}
+
+func (r zstdReadCloser) Read(p []byte) (int, error) {
+	return r.d.Read(p)
+}
+
+func (r zstdReadCloser) Close() error {
+	r.d.Close()
+	return nil
+}
+
+type tarFormat struct {
+	compression compression
+}
+
+func (f tarFormat) Extension() string {
+	return ".tar" + f.compression.extension
+}
+
+func (f tarFormat) Create(outfile, dir string) error {
+	return createFile(f, outfile, dir)
+}
+
+func (f tarFormat) Write(w io.Writer, dir, skip string) error {
+	cw, err := f.compression.writer(w)
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(cw)
+
+	err = walk(dir, skip, func(p, rel string, info os.FileInfo) error {
+		var linkname string
+		var err error
+
+		if info.Mode()&os.ModeSymlink != 0 {
+			linkname, err = os.Readlink(p)
+			if err != nil {
+				return err
+			}
+		}
+
+		header, err := tar.FileInfoHeader(info, linkname)
+		if err != nil {
+			return err
+		}
+		header.Name = rel
+
+		if info.IsDir() {
+			header.Name += "/"
+		}
+
+		err = tw.WriteHeader(header)
+		if err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		return copyFile(tw, p)
+	})
+	if err != nil {
+		return err
+	}
+
+	err = tw.Close()
+	if err != nil {
+		return err
+	}
+
+	return cw.Close()
+}
+
+func copyFile(w io.Writer, p string) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+
+	return err
+}
+
+func (f tarFormat) Extract(archive, dir string) error {
+	in, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	cr, err := f.compression.reader(in)
+	if err != nil {
+		return err
+	}
+	defer cr.Close()
+
+	tr := tar.NewReader(cr)
+	e := newExtractor(dir)
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		mode := header.FileInfo().Mode()
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = e.mkdir(header.Name, mode, header.ModTime)
+		case tar.TypeReg, tar.TypeRegA:
+			err = e.file(header.Name, mode, header.ModTime, tr)
+		case tar.TypeSymlink:
+			err = e.symlink(header.Name, header.Linkname)
+		case tar.TypeLink:
+			err = e.link(header.Name, header.Linkname)
+		default:
+			err = fmt.Errorf("unsupported type of file '%s' in archive", header.Name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return e.finish()
+}
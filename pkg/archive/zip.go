@@ -0,0 +1,141 @@
+package archive
+
+import (
+	"archive/zip"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+type zipFormat struct{}
+
+func (zipFormat) Extension() string {
+	return ".zip"
+}
+
+func (f zipFormat) Create(outfile, dir string) error {
+	return createFile(f, outfile, dir)
+}
+
+func (zipFormat) Write(w io.Writer, dir, skip string) error {
+	zw := zip.NewWriter(w)
+
+	err := walk(dir, skip, func(p, rel string, info os.FileInfo) error {
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = rel
+
+		switch {
+		case info.IsDir():
+			header.Name += "/"
+			_, err = zw.CreateHeader(header)
+			return err
+		case info.Mode()&os.ModeSymlink != 0:
+			return addZipSymlink(zw, header, p)
+		default:
+			header.Method = zip.Deflate
+			return addZipFile(zw, header, p)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// Symlinks are stored in zip as files containing link target (the same way Info-ZIP does)
+func addZipSymlink(zw *zip.Writer, header *zip.FileHeader, p string) error {
+	linkname, err := os.Readlink(p)
+	if err != nil {
+		return err
+	}
+
+	w, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, linkname)
+
+	return err
+}
+
+func addZipFile(zw *zip.Writer, header *zip.FileHeader, p string) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, f)
+
+	return err
+}
+
+func (zipFormat) Extract(archive, dir string) error {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	e := newExtractor(dir)
+
+	for _, file := range zr.File {
+		mode := file.Mode()
+
+		switch {
+		case mode.IsDir():
+			err = e.mkdir(strings.TrimSuffix(file.Name, "/"), mode, file.Modified)
+		case mode&os.ModeSymlink != 0:
+			err = extractZipSymlink(e, file)
+		default:
+			err = extractZipFile(e, file)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return e.finish()
+}
+
+func extractZipSymlink(e *extractor, file *zip.File) error {
+	r, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	linkname, err := ioutil.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	return e.symlink(file.Name, string(linkname))
+}
+
+func extractZipFile(e *extractor, file *zip.File) error {
+	r, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	mode := file.Mode()
+	// Archives created by some tools don't have unix modes at all
+	if mode.Perm() == 0 {
+		mode |= 0644
+	}
+
+	return e.file(file.Name, mode, file.Modified, r)
+}
@@ -0,0 +1,218 @@
+package discovery
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/sirupsen/logrus"
+
+	"github.com/yurykabanov/backuper/pkg/domain"
+)
+
+const reconnectDelay = 5 * time.Second
+
+type dockerClient interface {
+	ContainerList(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error)
+	ContainerInspect(ctx context.Context, containerID string) (types.ContainerJSON, error)
+	Events(ctx context.Context, options types.EventsOptions) (<-chan events.Message, <-chan error)
+}
+
+// Registry accepts rules of discovered containers
+type Registry interface {
+	RegisterRule(rule domain.Rule) error
+	UnregisterRule(name string) error
+}
+
+// Discovery keeps rules of registry in sync with labels of running containers
+type Discovery struct {
+	logger   logrus.FieldLogger
+	docker   dockerClient
+	registry Registry
+
+	// Rule names by container id
+	containers map[string]string
+	mu         sync.Mutex
+}
+
+func New(logger logrus.FieldLogger, docker dockerClient, registry Registry) *Discovery {
+	return &Discovery{
+		logger:     logger,
+		docker:     docker,
+		registry:   registry,
+		containers: make(map[string]string),
+	}
+}
+
+func labelFilter() filters.Args {
+	args := filters.NewArgs()
+	args.Add("label", LabelCron)
+	return args
+}
+
+// Sync registers rules of all running labeled containers and unregisters
+// rules of containers which are gone
+func (d *Discovery) Sync(ctx context.Context) error {
+	containers, err := d.docker.ContainerList(ctx, types.ContainerListOptions{Filters: labelFilter()})
+	if err != nil {
+		return err
+	}
+
+	running := make(map[string]bool, len(containers))
+
+	for _, c := range containers {
+		running[c.ID] = true
+
+		d.add(ctx, c.ID)
+	}
+
+	d.mu.Lock()
+	var gone []string
+	for id := range d.containers {
+		if !running[id] {
+			gone = append(gone, id)
+		}
+	}
+	d.mu.Unlock()
+
+	for _, id := range gone {
+		d.remove(id)
+	}
+
+	return nil
+}
+
+// Watch follows container events until context is canceled, the state is
+// synced every time connection to docker is (re-)established
+func (d *Discovery) Watch(ctx context.Context) {
+	for {
+		err := d.watch(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		d.logger.WithError(err).Warn("Unable to watch container events, reconnecting")
+
+		select {
+		case <-time.After(reconnectDelay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (d *Discovery) watch(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	args := labelFilter()
+	args.Add("type", events.ContainerEventType)
+	args.Add("event", "start")
+	args.Add("event", "die")
+
+	// Subscribe before syncing so no event is missed in between
+	messages, errs := d.docker.Events(ctx, types.EventsOptions{Filters: args})
+
+	err := d.Sync(ctx)
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case msg := <-messages:
+			switch msg.Action {
+			case "start":
+				d.add(ctx, msg.Actor.ID)
+			case "die":
+				d.remove(msg.Actor.ID)
+			}
+		case err := <-errs:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (d *Discovery) tracked(id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	_, ok := d.containers[id]
+	return ok
+}
+
+// Labels can't be changed without recreating container,
+// so containers are registered only once
+func (d *Discovery) add(ctx context.Context, id string) {
+	if d.tracked(id) {
+		return
+	}
+
+	logger := d.logger.WithField("container_id", id)
+
+	c, err := d.docker.ContainerInspect(ctx, id)
+	if err != nil {
+		logger.WithError(err).Error("Unable to inspect container")
+		return
+	}
+
+	// Containers started by backuper itself inherit labels of the image
+	if _, ok := c.Config.Labels[domain.ManagedLabel]; ok {
+		return
+	}
+
+	rule, err := RuleFromContainer(c)
+	if err != nil {
+		logger.WithError(err).Warn("Container has invalid backup labels")
+		return
+	}
+
+	logger = logger.WithField("rule", rule.Name)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for other, name := range d.containers {
+		if name == rule.Name {
+			logger.WithField("other_container_id", other).Warn("Rule is already defined by another container")
+			return
+		}
+	}
+
+	err = d.registry.RegisterRule(rule)
+	if err != nil {
+		logger.WithError(err).Error("Unable to register discovered rule")
+		return
+	}
+
+	d.containers[id] = rule.Name
+
+	logger.Info("Discovered rule registered")
+}
+
+func (d *Discovery) remove(id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	name, ok := d.containers[id]
+	if !ok {
+		return
+	}
+
+	delete(d.containers, id)
+
+	logger := d.logger.WithField("container_id", id).WithField("rule", name)
+
+	err := d.registry.UnregisterRule(name)
+	if err != nil {
+		logger.WithError(err).Error("Unable to unregister discovered rule")
+		return
+	}
+
+	logger.Info("Discovered rule unregistered")
+}
@@ -0,0 +1,161 @@
+package discovery
+
+import (
+	"context"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yurykabanov/backuper/pkg/domain"
+)
+
+func discardLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	return logger
+}
+
+// region fakeDocker
+type fakeDocker struct {
+	mu         sync.Mutex
+	containers map[string]types.ContainerJSON
+
+	events chan events.Message
+	errs   chan error
+}
+
+func newFakeDocker() *fakeDocker {
+	return &fakeDocker{
+		containers: make(map[string]types.ContainerJSON),
+		events:     make(chan events.Message),
+		errs:       make(chan error),
+	}
+}
+
+func (d *fakeDocker) run(c types.ContainerJSON) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.containers[c.ID] = c
+}
+
+func (d *fakeDocker) stop(id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.containers, id)
+}
+
+func (d *fakeDocker) ContainerList(ctx context.Context, options types.ContainerListOptions) ([]types.Container, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var list []types.Container
+	for id, c := range d.containers {
+		list = append(list, types.Container{ID: id, Labels: c.Config.Labels})
+	}
+	return list, nil
+}
+
+func (d *fakeDocker) ContainerInspect(ctx context.Context, id string) (types.ContainerJSON, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.containers[id], nil
+}
+
+func (d *fakeDocker) Events(ctx context.Context, options types.EventsOptions) (<-chan events.Message, <-chan error) {
+	return d.events, d.errs
+}
+
+// endregion
+
+// region fakeRegistry
+type fakeRegistry struct {
+	calls chan string
+}
+
+func (r *fakeRegistry) RegisterRule(rule domain.Rule) error {
+	r.calls <- "register " + rule.Name
+	return nil
+}
+
+func (r *fakeRegistry) UnregisterRule(name string) error {
+	r.calls <- "unregister " + name
+	return nil
+}
+
+// endregion
+
+func backupLabels(extra map[string]string) map[string]string {
+	labels := map[string]string{LabelCron: "@daily", LabelStorage: "local", LabelCommand: "mysqldump"}
+	for k, v := range extra {
+		labels[k] = v
+	}
+	return labels
+}
+
+func TestDiscovery_Sync(t *testing.T) {
+	docker := newFakeDocker()
+	registry := &fakeRegistry{calls: make(chan string, 10)}
+
+	docker.run(containerJSON("a", "/mysql", "mysql:8", backupLabels(nil)))
+	// dumper containers started by backuper itself
+	docker.run(containerJSON("b", "/dumper", "mysql:8", backupLabels(map[string]string{domain.ManagedLabel: "true"})))
+	// invalid labels are skipped
+	docker.run(containerJSON("c", "/broken", "mysql:8", backupLabels(map[string]string{LabelArchive: "rar"})))
+	// rule name is already taken by another container
+	docker.run(containerJSON("d", "/replica", "mysql:8", backupLabels(map[string]string{LabelName: "mysql"})))
+
+	d := New(discardLogger(), docker, registry)
+
+	assert.Nil(t, d.Sync(context.Background()))
+	assert.Equal(t, "register mysql", <-registry.calls)
+	assert.Len(t, registry.calls, 0)
+
+	// already registered containers are not registered again
+	assert.Nil(t, d.Sync(context.Background()))
+	assert.Len(t, registry.calls, 0)
+
+	docker.stop("a")
+	docker.stop("d")
+
+	assert.Nil(t, d.Sync(context.Background()))
+	assert.Equal(t, "unregister mysql", <-registry.calls)
+	assert.Len(t, registry.calls, 0)
+}
+
+func TestDiscovery_Watch(t *testing.T) {
+	docker := newFakeDocker()
+	registry := &fakeRegistry{calls: make(chan string, 10)}
+
+	d := New(discardLogger(), docker, registry)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go d.Watch(ctx)
+
+	docker.run(containerJSON("a", "/mysql", "mysql:8", backupLabels(nil)))
+	docker.events <- events.Message{Type: events.ContainerEventType, Action: "start", Actor: events.Actor{ID: "a"}}
+
+	assert.Equal(t, "register mysql", receive(t, registry.calls))
+
+	docker.stop("a")
+	docker.events <- events.Message{Type: events.ContainerEventType, Action: "die", Actor: events.Actor{ID: "a"}}
+
+	assert.Equal(t, "unregister mysql", receive(t, registry.calls))
+}
+
+func receive(t *testing.T, calls <-chan string) string {
+	select {
+	case call := <-calls:
+		return call
+	case <-time.After(time.Second):
+		t.Fatal("registry was not called")
+		return ""
+	}
+}
@@ -0,0 +1,202 @@
+package discovery
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+
+	"github.com/yurykabanov/backuper/pkg/domain"
+)
+
+const (
+	labelPrefix = "backuper."
+
+	// Containers having this label are discovered
+	LabelCron = labelPrefix + "cron"
+
+	LabelName        = labelPrefix + "name"
+	LabelStorage     = labelPrefix + "storage"
+	LabelImage       = labelPrefix + "image"
+	LabelCommand     = labelPrefix + "command"
+	LabelTimeout     = labelPrefix + "timeout"
+	LabelRotation    = labelPrefix + "rotation"
+	LabelArchive     = labelPrefix + "archive"
+	LabelStreaming   = labelPrefix + "streaming"
+	LabelOutput      = labelPrefix + "output"
+	LabelOutputFile  = labelPrefix + "output_file"
+	LabelNetwork     = labelPrefix + "network"
+	LabelVolumesFrom = labelPrefix + "volumes_from"
+
+	// Prefix of labels defining environment: `backuper.env.KEY=value`
+	LabelEnvPrefix = labelPrefix + "env."
+)
+
+const (
+	defaultTimeout  = time.Hour
+	defaultRotation = "24h:7"
+)
+
+// RuleFromContainer builds rule from labels of the container:
+//
+//	backuper.cron          cron spec (required)
+//	backuper.storage       storage name or comma-separated names to replicate to (required)
+//	backuper.name          rule name, name of the container by default
+//	backuper.image         image of the container by default
+//	backuper.command       JSON array or command for `sh -c` (required unless backuper.image is specified)
+//	backuper.timeout       1h by default
+//	backuper.rotation      comma-separated period:preserve_at_most pairs, 24h:7 by default
+//	backuper.network       network namespace of the container by default
+//	backuper.volumes_from  comma-separated containers
+//	backuper.archive, backuper.streaming, backuper.output, backuper.output_file
+//	backuper.env.KEY       environment variable KEY
+func RuleFromContainer(c types.ContainerJSON) (domain.Rule, error) {
+	labels := c.Config.Labels
+
+	rule := domain.Rule{
+		Name:        labels[LabelName],
+		CronSpec:    labels[LabelCron],
+		StorageName: labels[LabelStorage],
+		Image:       labels[LabelImage],
+		Timeout:     defaultTimeout,
+		Archive:     labels[LabelArchive],
+		Output:      labels[LabelOutput],
+		OutputFile:  labels[LabelOutputFile],
+		Network:     labels[LabelNetwork],
+	}
+
+	if rule.CronSpec == "" {
+		return rule, fmt.Errorf("label %s is not specified", LabelCron)
+	}
+
+	if rule.StorageName == "" {
+		return rule, fmt.Errorf("label %s is not specified", LabelStorage)
+	}
+
+	if storages := splitList(rule.StorageName); len(storages) > 1 {
+		rule.StorageName, rule.Storages = "", storages
+	}
+
+	if rule.Name == "" {
+		rule.Name = strings.TrimPrefix(c.Name, "/")
+	}
+
+	// Default command of the container's own image would just start another
+	// copy of the service (e.g. database server) rather than backup it
+	if rule.Image == "" {
+		if labels[LabelCommand] == "" {
+			return rule, fmt.Errorf("label %s is required unless %s is specified", LabelCommand, LabelImage)
+		}
+
+		rule.Image = c.Config.Image
+	}
+
+	// Sharing network namespace makes services of the container reachable at localhost
+	if rule.Network == "" {
+		rule.Network = "container:" + c.ID
+	}
+
+	var err error
+
+	if v := labels[LabelCommand]; v != "" {
+		rule.Command, err = parseCommand(v)
+		if err != nil {
+			return rule, fmt.Errorf("invalid label %s: %s", LabelCommand, err)
+		}
+	}
+
+	if v := labels[LabelTimeout]; v != "" {
+		rule.Timeout, err = time.ParseDuration(v)
+		if err != nil {
+			return rule, fmt.Errorf("invalid label %s: %s", LabelTimeout, err)
+		}
+	}
+
+	rotation := labels[LabelRotation]
+	if rotation == "" {
+		rotation = defaultRotation
+	}
+
+	rule.RotationRules, err = parseRotation(rotation)
+	if err != nil {
+		return rule, fmt.Errorf("invalid label %s: %s", LabelRotation, err)
+	}
+
+	if v := labels[LabelStreaming]; v != "" {
+		rule.Streaming, err = strconv.ParseBool(v)
+		if err != nil {
+			return rule, fmt.Errorf("invalid label %s: %s", LabelStreaming, err)
+		}
+	}
+
+	if v := labels[LabelVolumesFrom]; v != "" {
+		rule.VolumesFrom = splitList(v)
+	}
+
+	for k, v := range labels {
+		if strings.HasPrefix(k, LabelEnvPrefix) && len(k) > len(LabelEnvPrefix) {
+			rule.Env = append(rule.Env, strings.TrimPrefix(k, LabelEnvPrefix)+"="+v)
+		}
+	}
+	sort.Strings(rule.Env)
+
+	return rule, rule.Validate()
+}
+
+// Command is either JSON array or shell command
+func parseCommand(v string) ([]string, error) {
+	if !strings.HasPrefix(strings.TrimSpace(v), "[") {
+		return []string{"sh", "-c", v}, nil
+	}
+
+	var command []string
+
+	err := json.Unmarshal([]byte(v), &command)
+	if err != nil {
+		return nil, err
+	}
+
+	return command, nil
+}
+
+// Rotation is a list of `period:preserve_at_most` pairs, e.g. `1h:24,24h:7`
+func parseRotation(v string) ([]domain.RotationRule, error) {
+	var rules []domain.RotationRule
+
+	for _, item := range splitList(v) {
+		parts := strings.Split(item, ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("expected period:preserve_at_most, got '%s'", item)
+		}
+
+		period, err := time.ParseDuration(parts[0])
+		if err != nil {
+			return nil, err
+		}
+
+		preserve, err := strconv.Atoi(parts[1])
+		if err != nil || preserve <= 0 {
+			return nil, fmt.Errorf("invalid preserve_at_most '%s'", parts[1])
+		}
+
+		rules = append(rules, domain.RotationRule{Period: period, PreserveAtMost: preserve})
+	}
+
+	return rules, nil
+}
+
+func splitList(v string) []string {
+	var items []string
+
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
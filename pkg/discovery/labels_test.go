@@ -0,0 +1,145 @@
+package discovery
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yurykabanov/backuper/pkg/domain"
+)
+
+func containerJSON(id, name, image string, labels map[string]string) types.ContainerJSON {
+	return types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{ID: id, Name: name},
+		Config:            &container.Config{Image: image, Labels: labels},
+	}
+}
+
+func TestRuleFromContainer_Defaults(t *testing.T) {
+	rule, err := RuleFromContainer(containerJSON("abc", "/mysql", "mysql:8", map[string]string{
+		LabelCron:    "@daily",
+		LabelStorage: "local",
+		LabelCommand: `["mysqldump", "--all-databases"]`,
+	}))
+
+	assert.Nil(t, err)
+	assert.Equal(t, domain.Rule{
+		Name:          "mysql",
+		CronSpec:      "@daily",
+		StorageName:   "local",
+		Image:         "mysql:8",
+		Command:       []string{"mysqldump", "--all-databases"},
+		Timeout:       time.Hour,
+		RotationRules: []domain.RotationRule{{Period: 24 * time.Hour, PreserveAtMost: 7}},
+		Network:       "container:abc",
+	}, rule)
+}
+
+func TestRuleFromContainer(t *testing.T) {
+	rule, err := RuleFromContainer(containerJSON("abc", "/mysql", "mysql:8", map[string]string{
+		LabelCron:               "0 0 * * * *",
+		LabelStorage:            "s3",
+		LabelName:               "mysql-main",
+		LabelImage:              "mysql:5.7",
+		LabelCommand:            `["mysqldump", "--all-databases"]`,
+		LabelTimeout:            "10m",
+		LabelRotation:           "1h:24, 24h:7",
+		LabelArchive:            "tar.gz",
+		LabelStreaming:          "true",
+		LabelOutput:             "stdout",
+		LabelOutputFile:         "dump.sql",
+		LabelNetwork:            "backend",
+		LabelVolumesFrom:        "mysql-data:ro",
+		LabelEnvPrefix + "USER": "root",
+		LabelEnvPrefix + "HOST": "mysql",
+	}))
+
+	assert.Nil(t, err)
+	assert.Equal(t, domain.Rule{
+		Name:        "mysql-main",
+		CronSpec:    "0 0 * * * *",
+		StorageName: "s3",
+		Image:       "mysql:5.7",
+		Command:     []string{"mysqldump", "--all-databases"},
+		Timeout:     10 * time.Minute,
+		RotationRules: []domain.RotationRule{
+			{Period: time.Hour, PreserveAtMost: 24},
+			{Period: 24 * time.Hour, PreserveAtMost: 7},
+		},
+		Archive:     "tar.gz",
+		Streaming:   true,
+		Output:      "stdout",
+		OutputFile:  "dump.sql",
+		Network:     "backend",
+		VolumesFrom: []string{"mysql-data:ro"},
+		Env:         []string{"HOST=mysql", "USER=root"},
+	}, rule)
+}
+
+func TestRuleFromContainer_ShellCommand(t *testing.T) {
+	rule, err := RuleFromContainer(containerJSON("abc", "/mysql", "mysql:8", map[string]string{
+		LabelCron:    "@daily",
+		LabelStorage: "local",
+		LabelCommand: "mysqldump > $BACKUP_TARGET_DIR/dump.sql",
+	}))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"sh", "-c", "mysqldump > $BACKUP_TARGET_DIR/dump.sql"}, rule.Command)
+}
+
+func TestRuleFromContainer_Storages(t *testing.T) {
+	rule, err := RuleFromContainer(containerJSON("abc", "/mysql", "mysql:8", map[string]string{
+		LabelCron:    "@daily",
+		LabelStorage: "local, s3",
+		LabelCommand: "mysqldump > $BACKUP_TARGET_DIR/dump.sql",
+	}))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "", rule.StorageName)
+	assert.Equal(t, []string{"local", "s3"}, rule.Storages)
+}
+
+// Image of the container is used only along with the command, its own
+// command would just start another copy of the service
+func TestRuleFromContainer_Command(t *testing.T) {
+	_, err := RuleFromContainer(containerJSON("abc", "/postgres", "postgres:11", map[string]string{
+		LabelCron:    "@daily",
+		LabelStorage: "local",
+	}))
+
+	assert.NotNil(t, err)
+
+	rule, err := RuleFromContainer(containerJSON("abc", "/postgres", "postgres:11", map[string]string{
+		LabelCron:    "@daily",
+		LabelStorage: "local",
+		LabelImage:   "some/pg-backup",
+	}))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "some/pg-backup", rule.Image)
+	assert.Nil(t, rule.Command)
+}
+
+func TestRuleFromContainer_Invalid(t *testing.T) {
+	const command = "mysqldump > $BACKUP_TARGET_DIR/dump.sql"
+
+	cases := []map[string]string{
+		{LabelStorage: "local", LabelCommand: command},
+		{LabelCron: "@daily", LabelCommand: command},
+		{LabelCron: "@daily", LabelStorage: "local", LabelCommand: "[broken"},
+		{LabelCron: "@daily", LabelStorage: "local", LabelCommand: command, LabelTimeout: "forever"},
+		{LabelCron: "@daily", LabelStorage: "local", LabelCommand: command, LabelRotation: "24h"},
+		{LabelCron: "@daily", LabelStorage: "local", LabelCommand: command, LabelRotation: "24h:0"},
+		{LabelCron: "@daily", LabelStorage: "local", LabelCommand: command, LabelStreaming: "maybe"},
+		{LabelCron: "@daily", LabelStorage: "local", LabelCommand: command, LabelArchive: "rar"},
+		{LabelCron: "@daily", LabelStorage: "local,local", LabelCommand: command},
+	}
+
+	for _, labels := range cases {
+		_, err := RuleFromContainer(containerJSON("abc", "/mysql", "mysql:8", labels))
+		assert.NotNil(t, err, "%v", labels)
+	}
+}
@@ -1,6 +1,9 @@
 package domain
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 type execStatus int
 
@@ -19,9 +22,39 @@ const (
 
 	// Backup created, dumper container finished, results are moved to target directory
 	ExecStatusSuccess
+
+	// Backup created, dumper container finished, but archive couldn't be transferred
+	// to storage, so it's kept in temp directory until transfer is retried
+	ExecStatusDumped
 )
 
-var ExecStatusUnfinished = []execStatus{ExecStatusNew, ExecStatusCreated, ExecStatusStarted}
+var ExecStatusUnfinished = []execStatus{ExecStatusNew, ExecStatusCreated, ExecStatusStarted, ExecStatusDumped}
+
+var execStatusNames = map[execStatus]string{
+	ExecStatusNew:     "new",
+	ExecStatusCreated: "created",
+	ExecStatusStarted: "started",
+	ExecStatusFailure: "failure",
+	ExecStatusSuccess: "success",
+	ExecStatusDumped:  "dumped",
+}
+
+func (s execStatus) String() string {
+	if name, ok := execStatusNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown(%d)", int(s))
+}
+
+// Parses status name as returned by String()
+func ParseExecStatus(name string) (execStatus, error) {
+	for s, n := range execStatusNames {
+		if n == name {
+			return s, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown exec status '%s'", name)
+}
 
 type Backup struct {
 	Id int64
@@ -32,6 +65,10 @@ type Backup struct {
 	// Unique container ID assigned by docker
 	ContainerId string
 
+	// ID of command executed in existing container (see Rule.ExecIn),
+	// ContainerId is the container in this case
+	ExecId string
+
 	// Directory within master container for given backup instance
 	// mounted to dumper container
 	TempDirectory string
@@ -59,16 +96,78 @@ type Backup struct {
 	// Generation of a backup
 	Generation int
 
-	// Name of storage
+	// Name of storage, the first storage having copy of the archive
+	// if rule has several of them (see BackupCopy)
 	StorageName string
 
+	// Original backup this one is retry of and number of the attempt (0 for original backup)
+	RetryOf *int64
+	Attempt int
+
+	// Number of retried transfers of archive (see ExecStatusDumped)
+	TransferAttempts int
+
+	// Time the backup is postponed to by retry policy, it's persisted
+	// so pending retries survive restart
+	RetryAt *time.Time
+
 	// Path to successful backup archive (in temp mount)
 	TempBackupFile string
 
-	// Path to successful backup archive (in local/remote mount)
+	// Path to successful backup archive (in local/remote mount of StorageName)
 	BackupFile string
 
 	CreatedAt  time.Time
 	FinishedAt *time.Time
 	DeletedAt  *time.Time
 }
+
+// Copy of backup archive stored in one of storages of the rule
+type BackupCopy struct {
+	BackupId    int64
+	StorageName string
+
+	// Either ExecStatusSuccess or ExecStatusFailure if archive couldn't be transferred
+	ExecStatus execStatus
+
+	// Path to backup archive in the storage
+	BackupFile string
+
+	// Generation of the copy according to rotation rules of the storage
+	Generation int
+
+	CreatedAt time.Time
+	DeletedAt *time.Time
+}
+
+// Criteria to search backups by, zero values are ignored
+type BackupFilter struct {
+	Rule       string
+	ExecStatus *execStatus
+	Generation *int
+
+	// Range of backup creation time: [CreatedFrom, CreatedTo)
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+
+	Limit  int
+	Offset int
+}
+
+// Number of backups sharing the same rule, status, generation and deletion state
+type BackupCount struct {
+	Rule       string
+	ExecStatus execStatus
+	Generation int
+	Deleted    bool
+	Count      int64
+}
+
+// Number of retained copies of successful backups sharing the same rule,
+// storage and generation
+type BackupCopyCount struct {
+	Rule        string
+	StorageName string
+	Generation  int
+	Count       int64
+}
@@ -0,0 +1,100 @@
+package domain
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Environment variable of container which value is read from file
+type Secret struct {
+	Env  string `mapstructure:"env"`
+	File string `mapstructure:"file"`
+}
+
+// ValidateEnv checks syntax of environment variables of the rule
+func (r Rule) ValidateEnv() error {
+	for _, e := range r.Env {
+		if i := strings.Index(e, "="); i <= 0 {
+			return fmt.Errorf("invalid env '%s', expected KEY=value", e)
+		}
+	}
+
+	for _, s := range r.Secrets {
+		if s.Env == "" || s.File == "" {
+			return fmt.Errorf("secret must have both env and file specified")
+		}
+	}
+
+	return nil
+}
+
+// containerEnv resolves environment variables of container of the rule:
+// variables from env files go first, then ones from env and secrets, so they
+// could override the former, and finally fixed variables which can't be overridden
+func (r Rule) containerEnv(fixed ...string) ([]string, error) {
+	var env []string
+
+	for _, name := range r.EnvFiles {
+		vars, err := readEnvFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read env file: %s", err)
+		}
+		env = append(env, vars...)
+	}
+
+	for _, e := range r.Env {
+		i := strings.Index(e, "=")
+		env = append(env, e[:i+1]+expandEnv(e[i+1:]))
+	}
+
+	for _, s := range r.Secrets {
+		data, err := ioutil.ReadFile(s.File)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read secret: %s", err)
+		}
+		env = append(env, s.Env+"="+strings.TrimRight(string(data), "\r\n"))
+	}
+
+	return append(env, fixed...), nil
+}
+
+// Expands ${VAR} and $VAR using environment of backuper itself, $$ stands for literal $
+func expandEnv(s string) string {
+	return os.Expand(s, func(name string) string {
+		if name == "$" {
+			return "$"
+		}
+		return os.Getenv(name)
+	})
+}
+
+// Reads file in format of `docker run --env-file`: KEY=value per line, values
+// are taken literally; lines without value are taken from environment of backuper
+func readEnvFile(name string) ([]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var env []string
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimLeft(scanner.Text(), " \t")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.Contains(line, "=") {
+			env = append(env, line)
+		} else if value, ok := os.LookupEnv(strings.TrimSpace(line)); ok {
+			env = append(env, strings.TrimSpace(line)+"="+value)
+		}
+	}
+
+	return env, scanner.Err()
+}
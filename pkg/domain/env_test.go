@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRule_containerEnv(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backuper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	envFile := path.Join(dir, "mysql.env")
+	err = ioutil.WriteFile(envFile, []byte("# credentials\nMYSQL_USER=backuper\n\nMYSQL_HOST\nMYSQL_UNSET\nMYSQL_PWD=from file\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secretFile := path.Join(dir, "password")
+	err = ioutil.WriteFile(secretFile, []byte("pa$$word\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("BACKUPER_TEST_HOST", "10.0.0.2")
+	os.Setenv("MYSQL_HOST", "db")
+	defer os.Unsetenv("BACKUPER_TEST_HOST")
+	defer os.Unsetenv("MYSQL_HOST")
+
+	rule := Rule{
+		Env:      []string{"MYSQL_TCP_HOST=${BACKUPER_TEST_HOST}", "PRICE=$$5"},
+		EnvFiles: []string{envFile},
+		Secrets:  []Secret{{Env: "MYSQL_PWD", File: secretFile}},
+	}
+
+	env, err := rule.containerEnv("BACKUP_TARGET_DIR=/__backup__")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{
+		"MYSQL_USER=backuper",
+		"MYSQL_HOST=db",
+		"MYSQL_PWD=from file",
+		"MYSQL_TCP_HOST=10.0.0.2",
+		"PRICE=$5",
+		"MYSQL_PWD=pa$$word",
+		"BACKUP_TARGET_DIR=/__backup__",
+	}, env)
+
+	_, err = Rule{Secrets: []Secret{{Env: "MYSQL_PWD", File: path.Join(dir, "missing")}}}.containerEnv()
+	assert.NotNil(t, err)
+}
+
+func TestRule_ValidateEnv(t *testing.T) {
+	assert.Nil(t, Rule{Env: []string{"A=", "B=c=d"}}.ValidateEnv())
+	assert.NotNil(t, Rule{Env: []string{"A"}}.ValidateEnv())
+	assert.NotNil(t, Rule{Env: []string{"=a"}}.ValidateEnv())
+	assert.NotNil(t, Rule{Secrets: []Secret{{Env: "A"}}}.ValidateEnv())
+}
@@ -0,0 +1,181 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/pkg/errors"
+)
+
+// Prefix of ExecIn selecting container by label instead of name
+const execLabelSelector = "label="
+
+// ValidateExec checks that the rule doesn't use options of dumper containers
+// when its command is executed in existing container
+func (r Rule) ValidateExec() error {
+	if r.ExecIn == "" {
+		return nil
+	}
+
+	if strings.TrimPrefix(r.ExecIn, execLabelSelector) == "" {
+		return fmt.Errorf("exec_in label is not specified")
+	}
+
+	if len(r.Command) == 0 {
+		return fmt.Errorf("command is required by exec_in")
+	}
+
+	if r.Output != "" && r.Output != OutputStdout {
+		return fmt.Errorf("output of exec_in must be stdout")
+	}
+
+	if len(r.Mounts) > 0 || len(r.VolumesFrom) > 0 {
+		return fmt.Errorf("mounts and volumes_from are not supported by exec_in")
+	}
+
+	if r.Network != "" || len(r.NetworkAliases) > 0 || len(r.Links) > 0 || len(r.ExtraHosts) > 0 {
+		return fmt.Errorf("network options are not supported by exec_in")
+	}
+
+	// Only user could be set for executed command, the rest belongs to the container
+	if r.CPUs != 0 || r.Memory != "" || r.WorkingDir != "" || r.ReadOnly || len(r.CapDrop) > 0 || len(r.SecurityOpt) > 0 {
+		return fmt.Errorf("limits, working_dir and security options are not supported by exec_in")
+	}
+
+	return nil
+}
+
+// execContainer finds running container by name (or id) or by label
+func (s *BackupService) execContainer(ctx context.Context, selector string) (string, error) {
+	if strings.HasPrefix(selector, execLabelSelector) {
+		args := filters.NewArgs()
+		args.Add("label", strings.TrimPrefix(selector, execLabelSelector))
+
+		containers, err := s.docker.ContainerList(ctx, types.ContainerListOptions{Filters: args})
+		if err != nil {
+			return "", err
+		}
+
+		if len(containers) != 1 {
+			return "", fmt.Errorf("expected exactly one running container matching '%s', found %d", selector, len(containers))
+		}
+
+		return containers[0].ID, nil
+	}
+
+	c, err := s.docker.ContainerInspect(ctx, selector)
+	if err != nil {
+		return "", err
+	}
+
+	if c.State == nil || !c.State.Running {
+		return "", fmt.Errorf("container '%s' is not running", selector)
+	}
+
+	return c.ID, nil
+}
+
+// startExec runs command of the rule in existing container capturing its stdout
+func (s *BackupService) startExec(ctx context.Context, rule Rule, backup Backup) (Backup, error) {
+	env, err := rule.containerEnv()
+	if err != nil {
+		return backup, err
+	}
+
+	containerId, err := s.execContainer(ctx, rule.ExecIn)
+	if err != nil {
+		return backup, err
+	}
+
+	dir, err := s.mountManager.AllocateTemp()
+	if err != nil {
+		return backup, err
+	}
+
+	backup.TempDirectory = dir
+
+	err = s.repo.Update(ctx, backup)
+	if err != nil {
+		return backup, err
+	}
+
+	config := types.ExecConfig{
+		User:         rule.User,
+		Cmd:          rule.Command,
+		Env:          env,
+		AttachStdout: true,
+		AttachStderr: true,
+	}
+
+	exec, err := s.docker.ContainerExecCreate(ctx, containerId, config)
+	if err != nil {
+		return backup, err
+	}
+
+	// Attaching starts the command, so no output is lost
+	err = s.captureOutput(rule, exec.ID, dir, func() (types.HijackedResponse, error) {
+		return s.docker.ContainerExecAttach(ctx, exec.ID, config)
+	})
+	if err != nil {
+		return backup, err
+	}
+
+	backup.ExecStatus = ExecStatusStarted
+	backup.ContainerId = containerId
+	backup.ExecId = exec.ID
+
+	err = s.repo.Update(context.Background(), backup)
+	if err != nil {
+		return backup, err
+	}
+
+	return backup, nil
+}
+
+// finishExec waits for command executed in existing container to exit,
+// the container itself is left intact
+func (s *BackupService) finishExec(ctx context.Context, rule Rule, backup Backup) (Backup, error) {
+	capture := s.detachOutput(backup.ExecId)
+
+	defer func() {
+		if capture != nil {
+			capture.close()
+			s.saveLogs(backup, capture)
+		}
+	}()
+
+	// Output can't be reattached after restart
+	if capture == nil {
+		_, _ = s.markWithStatusAndDeallocate(backup, ExecStatusFailure)
+
+		return backup, errors.New("output of exec has not been captured")
+	}
+
+	// On timeout only the output is detached, docker can't stop the command itself
+	err := capture.wait(ctx)
+	if err != nil {
+		_, _ = s.markWithStatusAndDeallocate(backup, ExecStatusFailure)
+
+		return backup, fmt.Errorf("unable to capture output: %s", err)
+	}
+
+	inspect, err := s.docker.ContainerExecInspect(ctx, backup.ExecId)
+	if err != nil {
+		_, _ = s.markWithStatusAndDeallocate(backup, ExecStatusFailure)
+
+		return backup, err
+	}
+
+	backup.StatusCode = int64(inspect.ExitCode)
+
+	if backup.StatusCode != 0 {
+		_, _ = s.markWithStatusAndDeallocate(backup, ExecStatusFailure)
+
+		return backup, errors.New("status code is not zero")
+	}
+
+	return s.storeResults(ctx, rule, backup)
+}
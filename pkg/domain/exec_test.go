@@ -0,0 +1,155 @@
+package domain
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRule_ValidateExec(t *testing.T) {
+	command := []string{"mysqldump", "--all-databases"}
+
+	valid := []Rule{
+		{},
+		{ExecIn: "mysql", Command: command},
+		{ExecIn: "label=com.docker.compose.service=mysql", Command: command, Output: OutputStdout},
+		{ExecIn: "mysql", Command: command, User: "mysql"},
+	}
+
+	for _, rule := range valid {
+		assert.Nil(t, rule.ValidateExec(), "%+v", rule)
+	}
+
+	invalid := []Rule{
+		{ExecIn: "mysql"},
+		{ExecIn: "label=", Command: command},
+		{ExecIn: "mysql", Command: command, Output: OutputDirectory},
+		{ExecIn: "mysql", Command: command, VolumesFrom: []string{"data"}},
+		{ExecIn: "mysql", Command: command, Network: "bridge"},
+		{ExecIn: "mysql", Command: command, Memory: "1g"},
+	}
+
+	for _, rule := range invalid {
+		assert.NotNil(t, rule.ValidateExec(), "%+v", rule)
+	}
+}
+
+func TestService_Backup_ExecIn(t *testing.T) {
+	repo := &backupRepositoryMock{}
+	dockerClient := &dockerClientMock{}
+	mountManager := &mountManagerMock{}
+	transferManager := &transferManagerMock{}
+
+	tempDirectory, err := ioutil.TempDir("", "backuper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDirectory)
+
+	rule := Rule{
+		Name:       "some-rule",
+		ExecIn:     "label=com.docker.compose.service=mysql",
+		Command:    []string{"mysqldump", "--all-databases"},
+		Env:        []string{"MYSQL_PWD=secret"},
+		OutputFile: "all.sql",
+		Streaming:  true,
+	}
+
+	ctx := context.Background()
+
+	client, server := net.Pipe()
+	go func() {
+		_, _ = stdcopy.NewStdWriter(server, stdcopy.Stdout).Write([]byte("some dump"))
+		_, _ = stdcopy.NewStdWriter(server, stdcopy.Stderr).Write([]byte("some warning"))
+		_ = server.Close()
+	}()
+
+	config := types.ExecConfig{
+		Cmd:          rule.Command,
+		Env:          []string{"MYSQL_PWD=secret"},
+		AttachStdout: true,
+		AttachStderr: true,
+	}
+
+	dockerClient.On("ContainerList", ctx, mock.MatchedBy(func(o types.ContainerListOptions) bool {
+		return o.Filters.ExactMatch("label", "com.docker.compose.service=mysql")
+	})).Return([]types.Container{{ID: "mysql-id"}}, nil)
+	dockerClient.On("ContainerExecCreate", ctx, "mysql-id", config).Return(types.IDResponse{ID: "exec-id"}, nil)
+	dockerClient.On("ContainerExecAttach", ctx, "exec-id", config).
+		Return(types.HijackedResponse{Conn: client, Reader: bufio.NewReader(client)}, nil)
+	dockerClient.On("ContainerExecInspect", ctx, "exec-id").Return(types.ContainerExecInspect{ExitCode: 0}, nil)
+
+	mountManager.On("AllocateTemp").Return(tempDirectory, nil)
+	mountManager.On("DeallocateTemp", tempDirectory).Return(nil)
+	repo.On("Update", mock.Anything, mock.AnythingOfType("Backup")).Return(nil)
+	repo.On("SaveLogs", mock.Anything, int64(42), "some warning").Return(nil).Once()
+
+	transferManager.On("TransferStream", mock.Anything, ".zip", mock.Anything).Return("/transfer/some_file.zip", nil)
+	repo.On("SaveCopy", mock.Anything, mock.AnythingOfType("BackupCopy")).Return(nil)
+
+	svc := NewBackupService(discardLogger(), repo, dockerClient, mountManager, transferManager, nil, nil)
+
+	backup, err := svc.StartBackup(ctx, rule, Backup{Id: 42, Rule: rule.Name})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	assert.Equal(t, "mysql-id", backup.ContainerId)
+	assert.Equal(t, "exec-id", backup.ExecId)
+
+	backup, err = svc.FinishBackup(ctx, rule, backup)
+
+	assert.Nil(t, err)
+	assert.Equal(t, ExecStatusSuccess, backup.ExecStatus)
+	repo.AssertExpectations(t)
+	transferManager.AssertExpectations(t)
+
+	// container of exec must never be removed
+	dockerClient.AssertNotCalled(t, "ContainerRemove", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestService_StartBackup_ExecIn_NotRunning(t *testing.T) {
+	repo := &backupRepositoryMock{}
+	dockerClient := &dockerClientMock{}
+
+	ctx := context.Background()
+
+	dockerClient.On("ContainerInspect", ctx, "mysql").
+		Return(types.ContainerJSON{ContainerJSONBase: &types.ContainerJSONBase{ID: "mysql-id", State: &types.ContainerState{}}}, nil)
+	repo.On("Update", mock.Anything, mock.MatchedBy(func(b Backup) bool {
+		return b.ExecStatus == ExecStatusFailure
+	})).Return(nil).Once()
+
+	svc := NewBackupService(discardLogger(), repo, dockerClient, nil, nil, nil, nil)
+
+	_, err := svc.StartBackup(ctx, Rule{Name: "some-rule", ExecIn: "mysql", Command: []string{"mysqldump"}}, Backup{Id: 42})
+
+	assert.NotNil(t, err)
+	repo.AssertExpectations(t)
+}
+
+func TestService_AbortBackup_ExecIn(t *testing.T) {
+	repo := &backupRepositoryMock{}
+	dockerClient := &dockerClientMock{}
+	mountManager := &mountManagerMock{}
+
+	repo.On("Update", mock.Anything, mock.MatchedBy(func(b Backup) bool {
+		return b.ExecStatus == ExecStatusFailure
+	})).Return(nil)
+	mountManager.On("DeallocateTemp", "/tmp/some-dir").Return(nil)
+
+	svc := NewBackupService(discardLogger(), repo, dockerClient, mountManager, nil, nil, nil)
+
+	err := svc.AbortBackup(context.Background(), Backup{Id: 42, ContainerId: "mysql-id", ExecId: "exec-id", TempDirectory: "/tmp/some-dir"})
+
+	assert.Nil(t, err)
+	dockerClient.AssertNotCalled(t, "ContainerRemove", mock.Anything, mock.Anything, mock.Anything)
+}
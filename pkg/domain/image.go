@@ -0,0 +1,75 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/docker/distribution/reference"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+)
+
+const (
+	// Image is pulled before every run (default)
+	ImagePullAlways = "always"
+	// Image is pulled only if it isn't present locally
+	ImagePullIfNotPresent = "if-not-present"
+	// Image is never pulled and must be present locally
+	ImagePullNever = "never"
+)
+
+// pullImage makes sure image is present locally according to pull policy
+func (s *BackupService) pullImage(ctx context.Context, ref reference.Named, policy string) error {
+	if policy == ImagePullIfNotPresent || policy == ImagePullNever {
+		present, err := s.imagePresent(ctx, ref)
+		if err != nil {
+			return err
+		}
+
+		if present {
+			return nil
+		}
+
+		if policy == ImagePullNever {
+			return fmt.Errorf("image '%s' is not present and pull policy is '%s'", ref, policy)
+		}
+	}
+
+	var options types.ImagePullOptions
+
+	if s.registryAuth != nil {
+		auth, err := s.registryAuth.EncodedAuth(reference.Domain(ref))
+		if err != nil {
+			return err
+		}
+
+		options.RegistryAuth = auth
+	}
+
+	img, err := s.docker.ImagePull(ctx, ref.String(), options)
+	if err != nil {
+		return err
+	}
+	defer img.Close()
+
+	_, err = io.Copy(ioutil.Discard, img)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (s *BackupService) imagePresent(ctx context.Context, ref reference.Named) (bool, error) {
+	_, _, err := s.docker.ImageInspectWithRaw(ctx, ref.String())
+	if client.IsErrImageNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
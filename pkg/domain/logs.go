@@ -0,0 +1,67 @@
+package domain
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+const (
+	// Logs of dumper container are stored bounded by both number of lines and size
+	maxLogLines = 200
+	maxLogSize  = 64 * 1024
+)
+
+// saveLogs stores tail of stdout and stderr of dumper container along with the backup,
+// it must be called before the container is removed
+func (s *BackupService) saveLogs(backup Backup, capture *outputCapture) {
+	logger := s.logger.WithField("backup_id", backup.Id)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var logs string
+
+	if capture != nil {
+		// Stdout is the backup itself and docker doesn't keep logs of such containers
+		logs = capture.stderr.String()
+	} else {
+		var err error
+
+		logs, err = s.containerLogs(ctx, backup.ContainerId)
+		if err != nil {
+			logger.WithError(err).Warn("Unable to read logs of container")
+			return
+		}
+	}
+
+	err := s.repo.SaveLogs(ctx, backup.Id, logs)
+	if err != nil {
+		logger.WithError(err).Warn("Unable to save logs of container")
+	}
+}
+
+func (s *BackupService) containerLogs(ctx context.Context, containerId string) (string, error) {
+	r, err := s.docker.ContainerLogs(ctx, containerId, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Tail:       strconv.Itoa(maxLogLines),
+	})
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+
+	// Both streams are interleaved into single one as they would appear in terminal
+	buf := &tailBuffer{max: maxLogSize, lines: maxLogLines}
+
+	_, err = stdcopy.StdCopy(buf, buf, r)
+	if err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
@@ -2,6 +2,7 @@ package domain
 
 import (
 	"context"
+	"sort"
 	"sync"
 	"time"
 
@@ -18,6 +19,25 @@ type BackupManager struct {
 
 	rules  map[string]Rule
 	active map[string]chan Backup
+	// Closed when rule is unregistered to stop its handler
+	stop map[string]chan struct{}
+	// Rules whose handler is busy with a backup right now
+	inFlight map[string]bool
+	// Id of backup being dispatched until its handler takes it in flight
+	// (zero while the backup is being created), see `dispatch`
+	dispatching map[string]int64
+	// Closed when handler of the rule returns, see `startHandler`
+	done map[string]chan struct{}
+	// Rules from configuration which can't be changed at runtime
+	static map[string]bool
+	// Cron entries by rule name and spec, see `schedule`
+	scheduled map[cronEntry]bool
+	running   bool
+
+	// Guards all of the above and dispatching backups into `active`
+	mu sync.Mutex
+
+	handlers sync.WaitGroup
 
 	service backupService
 	repo    BackupRepository
@@ -25,6 +45,11 @@ type BackupManager struct {
 	cron cron
 }
 
+type cronEntry struct {
+	rule string
+	spec string
+}
+
 func NewBackupManager(
 	logger logrus.FieldLogger,
 	rules []Rule,
@@ -32,32 +57,40 @@ func NewBackupManager(
 	repo BackupRepository,
 	cron cron,
 ) *BackupManager {
-	active := make(map[string]chan Backup, len(rules))
-	rulesMap := make(map[string]Rule)
-
-	for _, rule := range rules {
-		rulesMap[rule.Name] = rule
-		active[rule.Name] = make(chan Backup, 1)
-	}
-
-	return &BackupManager{
+	m := &BackupManager{
 		logger: logger,
 
-		rules:  rulesMap,
-		active: active,
+		rules:     make(map[string]Rule, len(rules)),
+		active:    make(map[string]chan Backup, len(rules)),
+		stop:      make(map[string]chan struct{}, len(rules)),
+		inFlight:    make(map[string]bool, len(rules)),
+		dispatching: make(map[string]int64, len(rules)),
+		done:        make(map[string]chan struct{}, len(rules)),
+		static:      make(map[string]bool, len(rules)),
+		scheduled:   make(map[cronEntry]bool),
 
 		service: service,
 		repo:    repo,
 
 		cron: cron,
 	}
+
+	for _, rule := range rules {
+		m.addRule(rule)
+		m.static[rule.Name] = true
+	}
+
+	return m
 }
 
 type backupService interface {
-	StartBackup(context.Context, Rule) (Backup, error)
-	FinishBackup(context.Context, Backup) (Backup, error)
+	StartBackup(context.Context, Rule, Backup) (Backup, error)
+	FinishBackup(context.Context, Rule, Backup) (Backup, error)
+	TransferBackup(context.Context, Rule, Backup) (Backup, error)
 	AbortBackup(context.Context, Backup) error
 	DeleteBackup(context.Context, Backup) error
+	DeleteCopy(context.Context, Backup) error
+	RestoreBackup(context.Context, Rule, Backup) error
 }
 
 type cron interface {
@@ -76,31 +109,211 @@ func (m *BackupManager) Run() {
 		m.logger.WithField("total_unfinished_backups", len(backups)).Info("Trying to continue managing unfinished backups")
 	}
 
-	// enqueue or abort unfinished backups
+	// enqueue or abort unfinished backups, pending retries are enqueued on time
 	for _, backup := range backups {
-		go m.enqueueOrAbort(context.Background(), backup)
+		go m.enqueueAt(context.Background(), backup)
 	}
 
+	m.mu.Lock()
+
 	// register handlers in go cron for every rule
-	for rule, ch := range m.active {
-		err = m.registerRule(rule, ch)
+	for name, rule := range m.rules {
+		err = m.schedule(rule)
 		if err != nil {
-			m.logger.WithField("spec", m.rules[rule].CronSpec).Fatalf("Invalid cron spec: '%s'", m.rules[rule].CronSpec)
+			m.logger.WithField("spec", rule.CronSpec).Fatalf("Invalid cron spec: '%s'", rule.CronSpec)
 		}
+
+		// start goroutines for each rule & chan from `m.active`
+		m.startHandler(name)
 	}
 
+	m.running = true
+	m.mu.Unlock()
+
 	m.logger.Debug("Starting cron")
 	m.cron.Start()
 
-	wg := &sync.WaitGroup{}
-	wg.Add(len(m.active))
+	m.handlers.Wait()
+}
+
+// RegisterRule adds new rule or replaces existing one at runtime
+func (m *BackupManager) RegisterRule(rule Rule) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.static[rule.Name] {
+		return ErrRuleConflict
+	}
+
+	err := m.schedule(rule)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := m.rules[rule.Name]; ok {
+		// Handler takes the new rule for the next backup
+		m.rules[rule.Name] = rule
+		return nil
+	}
+
+	m.addRule(rule)
 
-	// start goroutines for each rule & chan from `m.active`
-	for rule, ch := range m.active {
-		go m.handleRuleBackups(wg, m.rules[rule], ch)
+	if m.running {
+		m.startHandler(rule.Name)
 	}
 
-	wg.Wait()
+	return nil
+}
+
+// UnregisterRule removes rule previously added by RegisterRule,
+// backups already dispatched for the rule are still handled (see `startHandler`)
+func (m *BackupManager) UnregisterRule(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.static[name] {
+		return ErrRuleConflict
+	}
+
+	if _, ok := m.rules[name]; !ok {
+		return ErrRuleNotFound
+	}
+
+	close(m.stop[name])
+
+	delete(m.rules, name)
+	delete(m.active, name)
+	delete(m.stop, name)
+
+	return nil
+}
+
+// Done returns channel closed once handler of the rule has exited, i.e. backups
+// dispatched for the rule before it was unregistered have been handled
+func (m *BackupManager) Done(name string) <-chan struct{} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if done, ok := m.done[name]; ok {
+		return done
+	}
+
+	done := make(chan struct{})
+	close(done)
+
+	return done
+}
+
+// Rules returns currently registered rules ordered by name
+func (m *BackupManager) Rules() []Rule {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rules := make([]Rule, 0, len(m.rules))
+	for _, rule := range m.rules {
+		rules = append(rules, rule)
+	}
+
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Name < rules[j].Name })
+
+	return rules
+}
+
+func (m *BackupManager) rule(name string) (Rule, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rule, ok := m.rules[name]
+	return rule, ok
+}
+
+// Must be called with `mu` held
+func (m *BackupManager) addRule(rule Rule) {
+	m.rules[rule.Name] = rule
+	m.active[rule.Name] = make(chan Backup, 1)
+	m.stop[rule.Name] = make(chan struct{})
+}
+
+// Handler of re-registered rule (e.g. container has been restarted) starts
+// only after the previous one has finished its backup, so backups of the
+// same rule never run concurrently.
+// Must be called with `mu` held
+func (m *BackupManager) startHandler(name string) {
+	prev := m.done[name]
+	done := make(chan struct{})
+	m.done[name] = done
+
+	rule, ch, stop := m.rules[name], m.active[name], m.stop[name]
+
+	m.handlers.Add(1)
+	go func() {
+		if prev != nil {
+			<-prev
+		}
+
+		m.handleRuleBackups(rule, ch, stop)
+
+		m.mu.Lock()
+		if m.done[name] == done {
+			delete(m.done, name)
+		}
+		m.mu.Unlock()
+
+		close(done)
+	}()
+}
+
+// Restore restores backup with given id using restore command of its rule
+func (m *BackupManager) Restore(ctx context.Context, id int64) error {
+	ctx = appcontext.WithBackupId(ctx, id)
+
+	backup, err := m.repo.FindById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	// Restore runs without discovery, so only rules from configuration are known
+	rule, ok := m.rule(backup.Rule)
+	if !ok {
+		return ErrRestoreRuleNotFound
+	}
+
+	ctx = appcontext.WithRuleName(ctx, rule.Name)
+
+	timeout := rule.RestoreTimeout
+	if timeout == 0 {
+		timeout = rule.Timeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	logger := appcontext.LoggerFromContext(m.logger, ctx)
+
+	logger.Info("Restoring backup")
+
+	return m.service.RestoreBackup(ctx, rule, backup)
+}
+
+// enqueueAt waits until time backup is postponed to (see Backup.RetryAt)
+// and enqueues it, backup waits for the rule's queue to be free rather
+// than being dropped
+func (m *BackupManager) enqueueAt(ctx context.Context, backup Backup) {
+	if backup.RetryAt != nil {
+		m.mu.Lock()
+		stop := m.stop[backup.Rule]
+		m.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(*backup.RetryAt))
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-stop:
+		}
+	}
+
+	m.enqueueOrAbort(ctx, backup)
 }
 
 func (m *BackupManager) enqueueOrAbort(ctx context.Context, backup Backup) {
@@ -108,10 +321,19 @@ func (m *BackupManager) enqueueOrAbort(ctx context.Context, backup Backup) {
 
 	logger := appcontext.LoggerFromContext(m.logger, ctx)
 
-	if ch, ok := m.active[backup.Rule]; ok {
+	m.mu.Lock()
+	ch, ok := m.active[backup.Rule]
+	stop := m.stop[backup.Rule]
+	m.mu.Unlock()
+
+	if ok {
 		logger.Debug("Resuming backup")
-		ch <- backup
-		return
+
+		select {
+		case ch <- backup:
+			return
+		case <-stop:
+		}
 	}
 
 	logger.Warn("Aborting backup due to rule became unavailable")
@@ -122,17 +344,52 @@ func (m *BackupManager) enqueueOrAbort(ctx context.Context, backup Backup) {
 	}
 }
 
-func (m *BackupManager) handleRuleBackups(wg *sync.WaitGroup, rule Rule, ch <-chan Backup) {
+func (m *BackupManager) handleRuleBackups(rule Rule, ch <-chan Backup, stop <-chan struct{}) {
+	defer m.handlers.Done()
+
 	baseCtx := appcontext.WithRuleName(context.Background(), rule.Name)
 	logger := appcontext.LoggerFromContext(m.logger, baseCtx)
 
 	logger.WithFields(logrus.Fields{"spec": rule.CronSpec}).Debug("Starting rule handler")
 
-	for backup := range ch {
-		m.handleRuleBackup(baseCtx, rule, backup)
+	for {
+		select {
+		case backup := <-ch:
+			// Rule could have been replaced at runtime
+			if current, ok := m.rule(rule.Name); ok {
+				rule = current
+			}
+
+			m.handleInFlight(baseCtx, rule, backup)
+		case <-stop:
+			select {
+			case backup := <-ch:
+				m.handleInFlight(baseCtx, rule, backup)
+			default:
+			}
+
+			logger.Debug("Stopping rule handler")
+			return
+		}
+	}
+}
+
+// handleInFlight handles backup marking the rule busy meanwhile, see `dispatchBackup`
+func (m *BackupManager) handleInFlight(ctx context.Context, rule Rule, backup Backup) {
+	m.mu.Lock()
+	m.inFlight[rule.Name] = true
+	if id, ok := m.dispatching[rule.Name]; ok && id == backup.Id {
+		delete(m.dispatching, rule.Name)
 	}
+	m.mu.Unlock()
 
-	wg.Done()
+	defer func() {
+		m.mu.Lock()
+		delete(m.inFlight, rule.Name)
+		m.mu.Unlock()
+	}()
+
+	m.handleRuleBackup(ctx, rule, backup)
 }
 
 func (m *BackupManager) handleRuleBackup(ctx context.Context, rule Rule, backup Backup) {
@@ -140,34 +397,49 @@ func (m *BackupManager) handleRuleBackup(ctx context.Context, rule Rule, backup
 
 	logger.Info("Handling new backup task")
 
-	// for new backups: perform `service.StartBackup`
-	if backup.ExecStatus == ExecStatusNew {
-		backup = m.startBackup(ctx, rule, backup)
+	var err error
+
+	if backup.ExecStatus == ExecStatusDumped {
+		// for dumped backups only transfer of their archive is retried
+		backup, err = m.transferBackup(appcontext.WithBackupId(ctx, backup.Id), rule, backup)
+	} else {
+		// for new backups: perform `service.StartBackup`
+		if backup.ExecStatus == ExecStatusNew {
+			backup, err = m.startBackup(appcontext.WithBackupId(ctx, backup.Id), rule, backup)
+		}
+
+		// for both new and previously unfinished backups: perform `service.FinishBackup`
+		var finishErr error
+		backup, finishErr = m.awaitBackupFinish(appcontext.WithBackupId(ctx, backup.Id), rule, backup)
+		if err == nil {
+			err = finishErr
+		}
 	}
 
-	// for both new and previously unfinished backups: perform `service.FinishBackup`
-	m.awaitBackupFinish(appcontext.WithBackupId(ctx, backup.Id), rule, backup)
+	if err != nil {
+		m.scheduleRetry(appcontext.WithBackupId(ctx, backup.Id), rule, backup)
+	}
 
 	// sweep old backups if any
 	m.sweepOldBackups(ctx, rule)
 }
 
-func (m *BackupManager) startBackup(ctx context.Context, rule Rule, backup Backup) Backup {
+func (m *BackupManager) startBackup(ctx context.Context, rule Rule, backup Backup) (Backup, error) {
 	ctx, cancel := context.WithTimeout(ctx, rule.Timeout)
 	defer cancel()
 
 	logger := appcontext.LoggerFromContext(m.logger, ctx)
 
 	logger.Info("Starting new backup")
-	backup, err := m.service.StartBackup(ctx, rule)
+	backup, err := m.service.StartBackup(ctx, rule, backup)
 	if err != nil {
 		logger.WithError(err).Error("Unable to start backup")
 	}
 
-	return backup
+	return backup, err
 }
 
-func (m *BackupManager) awaitBackupFinish(ctx context.Context, rule Rule, backup Backup) {
+func (m *BackupManager) awaitBackupFinish(ctx context.Context, rule Rule, backup Backup) (Backup, error) {
 	ctx = appcontext.WithContainerId(ctx, backup.ContainerId)
 	ctx, cancel := context.WithDeadline(ctx, backup.CreatedAt.Add(rule.Timeout))
 	defer cancel()
@@ -175,12 +447,46 @@ func (m *BackupManager) awaitBackupFinish(ctx context.Context, rule Rule, backup
 	logger := appcontext.LoggerFromContext(m.logger, ctx)
 
 	logger.Info("Awaiting backup to finish")
-	backup, err := m.service.FinishBackup(ctx, backup)
+	backup, err := m.service.FinishBackup(ctx, rule, backup)
 	if err != nil {
 		logger.WithError(err).Error("Unable to finish backup")
 	}
 
 	logger.WithField("status_code", backup.StatusCode).Info("Backup finished")
+
+	return backup, err
+}
+
+func (m *BackupManager) transferBackup(ctx context.Context, rule Rule, backup Backup) (Backup, error) {
+	ctx, cancel := context.WithTimeout(ctx, rule.Timeout)
+	defer cancel()
+
+	logger := appcontext.LoggerFromContext(m.logger, ctx)
+
+	logger.WithField("transfer_attempt", backup.TransferAttempts+1).Info("Retrying transfer of dumped backup")
+	backup, err := m.service.TransferBackup(ctx, rule, backup)
+	if err != nil {
+		logger.WithError(err).Error("Unable to transfer backup")
+	}
+
+	return backup, err
+}
+
+// sweepOldBackups applies rotation rules (see `sweepStorage`) or retention
+// (see `sweepRetention`) of every storage of the rule to copies stored in it
+func (m *BackupManager) sweepOldBackups(ctx context.Context, rule Rule) {
+	logger := appcontext.LoggerFromContext(m.logger, ctx)
+
+	logger.Info("Sweeping old backups")
+
+	for _, storage := range rule.StorageNames() {
+		if retention := rule.retention(storage); retention != nil {
+			m.sweepRetention(ctx, rule, storage, *retention)
+			continue
+		}
+
+		m.sweepStorage(ctx, rule, storage)
+	}
 }
 
 // Each generation is considered as following:
@@ -209,21 +515,24 @@ func (m *BackupManager) awaitBackupFinish(ctx context.Context, rule Rule, backup
 //     generation 0:                 A1--[A2--A3--A4--A5]-->t
 //     generation 1: --B1-----B2-----__                   <- A1 will not be discarded, time_diff(A1, B2) is enough
 //
-func (m *BackupManager) sweepOldBackups(ctx context.Context, rule Rule) {
-	logger := appcontext.LoggerFromContext(m.logger, ctx)
+//
+// Generations are tracked per storage, so every storage could have its own rotation rules
+func (m *BackupManager) sweepStorage(ctx context.Context, rule Rule, storage string) {
+	logger := appcontext.LoggerFromContext(m.logger, ctx).WithField("storage", storage)
 
-	logger.Info("Sweeping old backups")
-	recentSuccessfulBackups, err := m.repo.FindAllSuccessfulNotDeleted(ctx, rule)
+	rotationRules := rule.StorageRotationRules(storage)
+
+	recentSuccessfulBackups, err := m.repo.FindAllSuccessfulNotDeletedCopies(ctx, rule, storage)
 	if err != nil {
 		logger.WithError(err).Error("Unable to query old backups")
 	}
 
 	backups := m.groupByGeneration(recentSuccessfulBackups)
-	maxGeneration := len(rule.RotationRules) - 1
+	maxGeneration := len(rotationRules) - 1
 
 	for generation := 0; generation <= maxGeneration; generation++ {
 		// How many backups will are candidates for pushing to the next generation
-		oldCount := len(backups[generation]) - rule.RotationRules[generation].PreserveAtMost
+		oldCount := len(backups[generation]) - rotationRules[generation].PreserveAtMost
 
 		// The `oldCount` value could be:
 		// - negative: current generation is not full
@@ -241,7 +550,7 @@ func (m *BackupManager) sweepOldBackups(ctx context.Context, rule Rule) {
 			logger.Infof("Found %d backups with generation %d, discarding them completely", oldCount, generation)
 
 			for _, backup := range oldBackups {
-				err = m.service.DeleteBackup(appcontext.WithBackupId(ctx, backup.Id), backup)
+				err = m.service.DeleteCopy(appcontext.WithBackupId(ctx, backup.Id), backup)
 				if err != nil {
 					logger.WithError(err).Error("Unable to delete backup")
 				}
@@ -257,10 +566,10 @@ func (m *BackupManager) sweepOldBackups(ctx context.Context, rule Rule) {
 				diffToNewestFromNextGeneration := old.CreatedAt.Sub(backups[generation+1][len(backups[generation+1])-1].CreatedAt)
 
 				// If item is not old enough (i.e. not enough time has passed to satisfy next generation's `Period` clause), then discard it
-				if diffToNewestFromNextGeneration.Seconds() < rule.RotationRules[generation+1].Period.Seconds() {
+				if diffToNewestFromNextGeneration.Seconds() < rotationRules[generation+1].Period.Seconds() {
 					logger.Infof("Discarding backup id=%d (generation %d) due to time difference is not enough for pushing it to the next generation", old.Id, generation)
 
-					err = m.service.DeleteBackup(appcontext.WithBackupId(ctx, old.Id), old)
+					err = m.service.DeleteCopy(appcontext.WithBackupId(ctx, old.Id), old)
 					if err != nil {
 						logger.WithError(err).Error("Unable to delete backup")
 					}
@@ -269,9 +578,9 @@ func (m *BackupManager) sweepOldBackups(ctx context.Context, rule Rule) {
 				}
 			}
 
-			logrus.Infof("Pushing backup id=%d from generation %d to %d", old.Id, generation, generation+1)
+			logger.Infof("Pushing backup id=%d from generation %d to %d", old.Id, generation, generation+1)
 			old.Generation += 1
-			err = m.repo.Update(appcontext.WithBackupId(ctx, old.Id), old)
+			err = m.repo.UpdateCopyGeneration(appcontext.WithBackupId(ctx, old.Id), old.Id, storage, old.Generation)
 			if err != nil {
 				logger.WithError(err).Error("Unable to update backup")
 			}
@@ -291,17 +600,121 @@ func (m *BackupManager) groupByGeneration(backups []Backup) map[int][]Backup {
 	return result
 }
 
-func (m *BackupManager) registerRule(rule string, ch chan<- Backup) error {
-	return m.cron.AddFunc(m.rules[rule].CronSpec, func() {
-		t := time.Now()
+// Adds cron entry for the rule unless there is one with the same spec already.
+// Cron doesn't support removal of entries, so entries are never removed: they
+// dispatch backups only while the rule is registered with the same spec. Entries
+// are reused when rule is registered again, so there is at most one entry per
+// distinct spec the rule has ever had until restart.
+// Must be called with `mu` held.
+func (m *BackupManager) schedule(rule Rule) error {
+	entry := cronEntry{rule: rule.Name, spec: rule.CronSpec}
+	if m.scheduled[entry] {
+		return nil
+	}
+
+	err := m.cron.AddFunc(rule.CronSpec, func() {
+		rule, ok := m.rule(entry.rule)
+		if !ok || rule.CronSpec != entry.spec {
+			return
+		}
 
-		fields := logrus.Fields{"rule": rule, "created_at": t}
+		backup, err := m.dispatch(context.Background(), rule, false)
 
+		fields := logrus.Fields{"rule": rule.Name, "backup_id": backup.Id, "created_at": backup.CreatedAt}
+
+		if err != nil {
+			m.logger.WithFields(fields).WithError(err).Warn("Unable to dispatch new backup")
+			return
+		}
+
+		m.logger.WithFields(fields).Info("Dispatched new backup")
+	})
+	if err != nil {
+		return err
+	}
+
+	m.scheduled[entry] = true
+
+	return nil
+}
+
+// Trigger dispatches new backup of given rule out of its schedule
+func (m *BackupManager) Trigger(ctx context.Context, ruleName string) (Backup, error) {
+	rule, ok := m.rule(ruleName)
+	if !ok {
+		return Backup{}, ErrRuleNotFound
+	}
+
+	backup, err := m.dispatch(ctx, rule, true)
+	if err != nil {
+		return backup, err
+	}
+
+	appcontext.LoggerFromContext(m.logger, appcontext.WithBackupId(ctx, backup.Id)).
+		WithField("rule", rule.Name).
+		Info("Dispatched new backup on demand")
+
+	return backup, nil
+}
+
+// Creates new backup and puts it into the rule's queue unless there is another
+// backup waiting in it already or, when `idle` is required (i.e. on demand),
+// the rule is handling another backup right now.
+// The rule is reserved while backup is being created, so concurrent dispatches
+// don't pass the check above until the handler takes the backup in flight
+func (m *BackupManager) dispatch(ctx context.Context, rule Rule, idle bool) (Backup, error) {
+	backup := Backup{
+		Rule:        rule.Name,
+		ExecStatus:  ExecStatusNew,
+		CreatedAt:   time.Now(),
+		StorageName: rule.StorageNames()[0],
+	}
+
+	m.mu.Lock()
+	ch, ok := m.active[backup.Rule]
+	if !ok {
+		m.mu.Unlock()
+		return Backup{}, ErrRuleNotFound
+	}
+	if _, reserved := m.dispatching[backup.Rule]; reserved || len(ch) == cap(ch) || idle && m.inFlight[backup.Rule] {
+		m.mu.Unlock()
+		return Backup{}, ErrRuleBusy
+	}
+	m.dispatching[backup.Rule] = 0
+	m.mu.Unlock()
+
+	backup, err := m.repo.Create(ctx, backup)
+	if err != nil {
+		m.mu.Lock()
+		delete(m.dispatching, rule.Name)
+		m.mu.Unlock()
+
+		return backup, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.active[rule.Name] == ch {
 		select {
-		case ch <- Backup{Rule: rule, CreatedAt: t}:
-			m.logger.WithFields(fields).Info("Dispatched new backup")
+		case ch <- backup:
+			m.dispatching[rule.Name] = backup.Id
+			return backup, nil
 		default:
-			m.logger.WithFields(fields).Warn("Unable to dispatch new backup")
+			// Queue has been taken by resumed backup in the meantime
+			err = ErrRuleBusy
 		}
-	})
+	} else {
+		// Rule has been unregistered while backup was being created
+		err = ErrRuleNotFound
+	}
+
+	delete(m.dispatching, rule.Name)
+
+	backup.ExecStatus = ExecStatusFailure
+	if err := m.repo.Update(context.Background(), backup); err != nil {
+		m.logger.WithError(err).WithField("backup_id", backup.Id).Error("Unable to mark backup failed")
+	}
+
+	return backup, err
 }
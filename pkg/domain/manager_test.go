@@ -0,0 +1,319 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// region cronMock
+type cronMock struct {
+	mock.Mock
+}
+
+func (m *cronMock) AddFunc(spec string, cmd func()) error {
+	args := m.Called(spec, cmd)
+	return args.Error(0)
+}
+
+func (m *cronMock) Start() {
+	m.Called()
+}
+
+// endregion
+
+func TestManager_Trigger(t *testing.T) {
+	repo := &backupRepositoryMock{}
+
+	rule := Rule{Name: "some-rule", StorageName: "some-storage"}
+
+	repo.On("Create", mock.Anything, mock.MatchedBy(func(b Backup) bool {
+		return b.Rule == rule.Name && b.StorageName == rule.StorageName && b.ExecStatus == ExecStatusNew
+	})).Return(Backup{Id: 42, Rule: rule.Name, ExecStatus: ExecStatusNew}, nil).Once()
+
+	m := NewBackupManager(discardLogger(), []Rule{rule}, nil, repo, nil)
+
+	backup, err := m.Trigger(context.Background(), rule.Name)
+
+	assert.Nil(t, err)
+	assert.Equal(t, int64(42), backup.Id)
+	assert.Equal(t, backup, <-m.active[rule.Name])
+
+	// queue of the rule already has pending backup
+	m.active[rule.Name] <- backup
+
+	_, err = m.Trigger(context.Background(), rule.Name)
+
+	assert.Equal(t, ErrRuleBusy, err)
+	repo.AssertExpectations(t)
+}
+
+func TestManager_Trigger_InFlight(t *testing.T) {
+	repo := &backupRepositoryMock{}
+	service := &backupServiceMock{}
+
+	rule := Rule{Name: "some-rule", StorageName: "some-storage", Timeout: time.Minute}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	repo.On("Create", mock.Anything, mock.AnythingOfType("Backup")).Return(Backup{Id: 42, Rule: rule.Name, ExecStatus: ExecStatusNew}, nil)
+	service.On("StartBackup", mock.Anything, rule, mock.AnythingOfType("Backup")).Run(func(mock.Arguments) {
+		close(started)
+		<-release
+	}).Return(Backup{Id: 42, Rule: rule.Name, ExecStatus: ExecStatusStarted}, nil).Once()
+	service.On("FinishBackup", mock.Anything, rule, mock.AnythingOfType("Backup")).Return(Backup{Id: 42, Rule: rule.Name, ExecStatus: ExecStatusSuccess}, nil).Once()
+	repo.On("FindAllSuccessfulNotDeletedCopies", mock.Anything, rule, "some-storage").Return([]Backup{}, nil)
+
+	m := NewBackupManager(discardLogger(), []Rule{rule}, service, repo, nil)
+
+	m.mu.Lock()
+	m.startHandler(rule.Name)
+	m.mu.Unlock()
+
+	_, err := m.Trigger(context.Background(), rule.Name)
+	assert.Nil(t, err)
+
+	<-started
+
+	// nothing is queued but the rule is busy with running backup
+	_, err = m.Trigger(context.Background(), rule.Name)
+	assert.Equal(t, ErrRuleBusy, err)
+
+	close(release)
+	close(m.stop[rule.Name])
+	m.handlers.Wait()
+
+	_, err = m.Trigger(context.Background(), rule.Name)
+	assert.Nil(t, err)
+
+	service.AssertExpectations(t)
+}
+
+func TestManager_Trigger_Concurrent(t *testing.T) {
+	repo := &backupRepositoryMock{}
+
+	rule := Rule{Name: "some-rule", StorageName: "some-storage"}
+
+	creating := make(chan struct{})
+	release := make(chan struct{})
+
+	repo.On("Create", mock.Anything, mock.AnythingOfType("Backup")).Run(func(mock.Arguments) {
+		close(creating)
+		<-release
+	}).Return(Backup{Id: 42, Rule: rule.Name, ExecStatus: ExecStatusNew}, nil).Once()
+
+	m := NewBackupManager(discardLogger(), []Rule{rule}, nil, repo, nil)
+
+	result := make(chan error)
+	go func() {
+		_, err := m.Trigger(context.Background(), rule.Name)
+		result <- err
+	}()
+
+	<-creating
+
+	// manager isn't locked while backup is being created, but the rule is reserved
+	assert.Len(t, m.Rules(), 1)
+
+	_, err := m.Trigger(context.Background(), rule.Name)
+	assert.Equal(t, ErrRuleBusy, err)
+
+	close(release)
+	assert.Nil(t, <-result)
+
+	// rule is still reserved when backup is taken from the queue until
+	// handler marks it in flight
+	<-m.active[rule.Name]
+
+	_, err = m.Trigger(context.Background(), rule.Name)
+	assert.Equal(t, ErrRuleBusy, err)
+
+	repo.AssertExpectations(t)
+}
+
+func TestManager_Trigger_RuleNotFound(t *testing.T) {
+	m := NewBackupManager(discardLogger(), nil, nil, &backupRepositoryMock{}, nil)
+
+	_, err := m.Trigger(context.Background(), "missing")
+
+	assert.Equal(t, ErrRuleNotFound, err)
+}
+
+func TestManager_Restore_RuleNotFound(t *testing.T) {
+	repo := &backupRepositoryMock{}
+	repo.On("FindById", mock.Anything, int64(42)).Return(Backup{Id: 42, Rule: "discovered", ExecStatus: ExecStatusSuccess}, nil)
+
+	m := NewBackupManager(discardLogger(), []Rule{{Name: "some-rule"}}, &backupServiceMock{}, repo, nil)
+
+	err := m.Restore(context.Background(), 42)
+
+	assert.Equal(t, ErrRestoreRuleNotFound, err)
+}
+
+func TestManager_RegisterRule(t *testing.T) {
+	static := Rule{Name: "static", CronSpec: "@daily"}
+	rule := Rule{Name: "discovered", CronSpec: "@hourly"}
+
+	c := &cronMock{}
+	c.On("AddFunc", "@hourly", mock.Anything).Return(nil).Once()
+	c.On("AddFunc", "@daily", mock.Anything).Return(nil).Once()
+
+	m := NewBackupManager(discardLogger(), []Rule{static}, nil, &backupRepositoryMock{}, c)
+
+	assert.Nil(t, m.RegisterRule(rule))
+	assert.Equal(t, []Rule{rule, static}, m.Rules())
+
+	// rules from configuration can't be replaced or removed
+	assert.Equal(t, ErrRuleConflict, m.RegisterRule(Rule{Name: "static", CronSpec: "@hourly"}))
+	assert.Equal(t, ErrRuleConflict, m.UnregisterRule("static"))
+
+	// same spec is scheduled only once
+	rule.StorageName = "other-storage"
+	assert.Nil(t, m.RegisterRule(rule))
+	assert.Equal(t, []Rule{rule, static}, m.Rules())
+
+	// changed spec is scheduled again
+	rule.CronSpec = "@daily"
+	assert.Nil(t, m.RegisterRule(rule))
+
+	assert.Nil(t, m.UnregisterRule(rule.Name))
+	assert.Equal(t, ErrRuleNotFound, m.UnregisterRule(rule.Name))
+	assert.Equal(t, []Rule{static}, m.Rules())
+
+	_, err := m.Trigger(context.Background(), rule.Name)
+	assert.Equal(t, ErrRuleNotFound, err)
+
+	c.AssertExpectations(t)
+}
+
+func TestManager_RegisterRule_Restarted(t *testing.T) {
+	repo := &backupRepositoryMock{}
+	service := &backupServiceMock{}
+
+	rule := Rule{Name: "discovered", CronSpec: "@daily", StorageName: "some-storage", Timeout: time.Minute}
+
+	c := &cronMock{}
+	c.On("AddFunc", "@daily", mock.Anything).Return(nil).Once()
+
+	started := make(chan int64, 2)
+	release := make(chan struct{})
+
+	repo.On("Create", mock.Anything, mock.AnythingOfType("Backup")).Return(Backup{Id: 42, Rule: rule.Name, ExecStatus: ExecStatusNew}, nil).Once()
+	repo.On("Create", mock.Anything, mock.AnythingOfType("Backup")).Return(Backup{Id: 43, Rule: rule.Name, ExecStatus: ExecStatusNew}, nil).Once()
+	service.On("StartBackup", mock.Anything, rule, mock.AnythingOfType("Backup")).Run(func(args mock.Arguments) {
+		started <- args.Get(2).(Backup).Id
+		<-release
+	}).Return(Backup{Rule: rule.Name, ExecStatus: ExecStatusStarted}, nil)
+	service.On("FinishBackup", mock.Anything, rule, mock.AnythingOfType("Backup")).Return(Backup{Rule: rule.Name, ExecStatus: ExecStatusSuccess}, nil)
+	repo.On("FindAllSuccessfulNotDeletedCopies", mock.Anything, rule, "some-storage").Return([]Backup{}, nil)
+
+	m := NewBackupManager(discardLogger(), nil, service, repo, c)
+	m.running = true
+
+	assert.Nil(t, m.RegisterRule(rule))
+
+	_, err := m.Trigger(context.Background(), rule.Name)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(42), <-started)
+
+	// container is restarted while its backup is running
+	assert.Nil(t, m.UnregisterRule(rule.Name))
+	assert.Nil(t, m.RegisterRule(rule))
+
+	_, err = m.Trigger(context.Background(), rule.Name)
+	assert.Equal(t, ErrRuleBusy, err)
+
+	// scheduled backup is queued but isn't started while previous handler is busy
+	_, err = m.dispatch(context.Background(), rule, false)
+	assert.Nil(t, err)
+
+	select {
+	case <-started:
+		t.Fatal("backup has been started while previous one is running")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+
+	select {
+	case id := <-started:
+		assert.Equal(t, int64(43), id)
+	case <-time.After(time.Second):
+		t.Fatal("backup has not been started after previous one has finished")
+	}
+
+	assert.Nil(t, m.UnregisterRule(rule.Name))
+	m.handlers.Wait()
+
+	c.AssertExpectations(t)
+}
+
+func TestManager_Done(t *testing.T) {
+	repo := &backupRepositoryMock{}
+	service := &backupServiceMock{}
+
+	rule := Rule{Name: "discovered", CronSpec: "@daily", StorageName: "some-storage", Timeout: time.Minute}
+
+	c := &cronMock{}
+	c.On("AddFunc", "@daily", mock.Anything).Return(nil).Once()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	repo.On("Create", mock.Anything, mock.AnythingOfType("Backup")).Return(Backup{Id: 42, Rule: rule.Name, ExecStatus: ExecStatusNew}, nil).Once()
+	service.On("StartBackup", mock.Anything, rule, mock.AnythingOfType("Backup")).Run(func(args mock.Arguments) {
+		close(started)
+		<-release
+	}).Return(Backup{Rule: rule.Name, ExecStatus: ExecStatusStarted}, nil)
+	service.On("FinishBackup", mock.Anything, rule, mock.AnythingOfType("Backup")).Return(Backup{Rule: rule.Name, ExecStatus: ExecStatusSuccess}, nil)
+	repo.On("FindAllSuccessfulNotDeletedCopies", mock.Anything, rule, "some-storage").Return([]Backup{}, nil)
+
+	m := NewBackupManager(discardLogger(), nil, service, repo, c)
+	m.running = true
+
+	assert.Nil(t, m.RegisterRule(rule))
+
+	_, err := m.Trigger(context.Background(), rule.Name)
+	assert.Nil(t, err)
+	<-started
+
+	done := m.Done(rule.Name)
+	assert.Nil(t, m.UnregisterRule(rule.Name))
+
+	// handler is still busy with backup dispatched before the rule was unregistered
+	select {
+	case <-done:
+		t.Fatal("handler has exited while its backup is running")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("handler has not exited after its backup has finished")
+	}
+
+	// unknown rule has no handler
+	select {
+	case <-m.Done("unknown"):
+	default:
+		t.Fatal("channel of unknown rule is not closed")
+	}
+}
+
+func TestManager_RegisterRule_InvalidSpec(t *testing.T) {
+	c := &cronMock{}
+	c.On("AddFunc", "invalid", mock.Anything).Return(errors.New("invalid spec"))
+
+	m := NewBackupManager(discardLogger(), nil, nil, &backupRepositoryMock{}, c)
+
+	assert.NotNil(t, m.RegisterRule(Rule{Name: "discovered", CronSpec: "invalid"}))
+	assert.Empty(t, m.Rules())
+}
@@ -0,0 +1,77 @@
+package domain
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+)
+
+// Additional mount of containers of the rule
+type Mount struct {
+	// Either bind or volume
+	Type string `mapstructure:"type"`
+	// Path on host (bind) or name of volume (volume)
+	Source   string `mapstructure:"source"`
+	Target   string `mapstructure:"target"`
+	ReadOnly bool   `mapstructure:"read_only"`
+}
+
+// Targets reserved for data of backup and restore
+var reservedMountTargets = []string{"/__backup__", "/__restore__"}
+
+// ValidateMounts checks additional mounts and volumes_from of the rule
+func (r Rule) ValidateMounts() error {
+	for _, m := range r.Mounts {
+		switch mount.Type(m.Type) {
+		case mount.TypeBind, mount.TypeVolume:
+		default:
+			return fmt.Errorf("unsupported mount type '%s', expected bind or volume", m.Type)
+		}
+
+		if m.Source == "" || m.Target == "" {
+			return fmt.Errorf("mount must have both source and target specified")
+		}
+
+		if !path.IsAbs(m.Target) {
+			return fmt.Errorf("mount target '%s' must be absolute path", m.Target)
+		}
+
+		for _, reserved := range reservedMountTargets {
+			if path.Clean(m.Target) == reserved {
+				return fmt.Errorf("mount target '%s' is reserved", m.Target)
+			}
+		}
+	}
+
+	for _, v := range r.VolumesFrom {
+		parts := strings.Split(v, ":")
+		if parts[0] == "" || len(parts) > 2 || (len(parts) == 2 && parts[1] != "ro" && parts[1] != "rw") {
+			return fmt.Errorf("invalid volumes_from '%s', expected container[:ro|rw]", v)
+		}
+	}
+
+	return nil
+}
+
+// applyMounts adds mounts and volumes of the rule to container, restore containers
+// get them writable regardless of configured mode since they have to write data back
+func (r Rule) applyMounts(hostConfig *container.HostConfig, restore bool) {
+	for _, m := range r.Mounts {
+		hostConfig.Mounts = append(hostConfig.Mounts, mount.Mount{
+			Type:     mount.Type(m.Type),
+			Source:   m.Source,
+			Target:   m.Target,
+			ReadOnly: m.ReadOnly && !restore,
+		})
+	}
+
+	for _, v := range r.VolumesFrom {
+		if restore {
+			v = strings.TrimSuffix(v, ":ro")
+		}
+		hostConfig.VolumesFrom = append(hostConfig.VolumesFrom, v)
+	}
+}
@@ -0,0 +1,54 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRule_ValidateMounts(t *testing.T) {
+	valid := Rule{
+		Mounts: []Mount{
+			{Type: "volume", Source: "wordpress_uploads", Target: "/data/uploads", ReadOnly: true},
+			{Type: "bind", Source: "/etc/nginx", Target: "/data/nginx"},
+		},
+		VolumesFrom: []string{"wordpress", "mysql:ro"},
+	}
+	assert.Nil(t, valid.ValidateMounts())
+
+	invalid := []Rule{
+		{Mounts: []Mount{{Type: "tmpfs", Source: "x", Target: "/x"}}},
+		{Mounts: []Mount{{Type: "volume", Target: "/x"}}},
+		{Mounts: []Mount{{Type: "volume", Source: "x", Target: "x"}}},
+		{Mounts: []Mount{{Type: "bind", Source: "/srv", Target: "/__backup__/"}}},
+		{VolumesFrom: []string{"mysql:rx"}},
+		{VolumesFrom: []string{":ro"}},
+	}
+	for _, rule := range invalid {
+		assert.NotNil(t, rule.ValidateMounts(), "%+v", rule)
+	}
+}
+
+func TestRule_applyMounts(t *testing.T) {
+	rule := Rule{
+		Mounts:      []Mount{{Type: "volume", Source: "uploads", Target: "/data/uploads", ReadOnly: true}},
+		VolumesFrom: []string{"mysql:ro"},
+	}
+
+	hostConfig := &container.HostConfig{}
+	rule.applyMounts(hostConfig, false)
+
+	assert.Equal(t, []mount.Mount{
+		{Type: mount.TypeVolume, Source: "uploads", Target: "/data/uploads", ReadOnly: true},
+	}, hostConfig.Mounts)
+	assert.Equal(t, []string{"mysql:ro"}, hostConfig.VolumesFrom)
+
+	// restore has to write data back
+	hostConfig = &container.HostConfig{}
+	rule.applyMounts(hostConfig, true)
+
+	assert.False(t, hostConfig.Mounts[0].ReadOnly)
+	assert.Equal(t, []string{"mysql"}, hostConfig.VolumesFrom)
+}
@@ -0,0 +1,62 @@
+package domain
+
+import (
+	"fmt"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+)
+
+// Containers use host network unless the rule specifies another one
+const defaultNetwork = "host"
+
+func (r Rule) networkMode() container.NetworkMode {
+	if r.Network == "" {
+		return defaultNetwork
+	}
+	return container.NetworkMode(r.Network)
+}
+
+// ValidateNetwork checks that network options of the rule could be applied together
+func (r Rule) ValidateNetwork() error {
+	mode := r.networkMode()
+
+	if len(r.NetworkAliases) > 0 && !mode.IsUserDefined() {
+		return fmt.Errorf("network aliases are supported only by user-defined networks, not '%s'", mode)
+	}
+
+	if len(r.Links) > 0 && !mode.IsUserDefined() && !mode.IsBridge() && !mode.IsDefault() {
+		return fmt.Errorf("links are not supported by '%s' network", mode)
+	}
+
+	if len(r.ExtraHosts) > 0 && (mode.IsContainer() || mode.IsNone()) {
+		return fmt.Errorf("extra hosts are not supported by '%s' network", mode)
+	}
+
+	return nil
+}
+
+// applyNetwork sets up network of container of the rule, links of user-defined
+// networks are configured per endpoint while legacy links of default bridge are set
+// in host config
+func (r Rule) applyNetwork(hostConfig *container.HostConfig) *network.NetworkingConfig {
+	mode := r.networkMode()
+
+	hostConfig.NetworkMode = mode
+	hostConfig.ExtraHosts = r.ExtraHosts
+
+	if !mode.IsUserDefined() {
+		hostConfig.Links = r.Links
+
+		return &network.NetworkingConfig{}
+	}
+
+	return &network.NetworkingConfig{
+		EndpointsConfig: map[string]*network.EndpointSettings{
+			r.Network: {
+				Aliases: r.NetworkAliases,
+				Links:   r.Links,
+			},
+		},
+	}
+}
@@ -0,0 +1,67 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/network"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRule_ValidateNetwork(t *testing.T) {
+	valid := []Rule{
+		{},
+		{Network: "host", ExtraHosts: []string{"db:10.0.0.2"}},
+		{Network: "bridge", Links: []string{"mysql:db"}},
+		{Network: "backend", NetworkAliases: []string{"backuper"}, Links: []string{"mysql:db"}},
+	}
+
+	for _, rule := range valid {
+		assert.Nil(t, rule.ValidateNetwork(), "%+v", rule)
+	}
+
+	invalid := []Rule{
+		{NetworkAliases: []string{"backuper"}},
+		{Network: "bridge", NetworkAliases: []string{"backuper"}},
+		{Links: []string{"mysql:db"}},
+		{Network: "none", ExtraHosts: []string{"db:10.0.0.2"}},
+		{Network: "container:mysql", Links: []string{"mysql:db"}},
+	}
+
+	for _, rule := range invalid {
+		assert.NotNil(t, rule.ValidateNetwork(), "%+v", rule)
+	}
+}
+
+func TestRule_applyNetwork(t *testing.T) {
+	hostConfig := &container.HostConfig{}
+	networkingConfig := Rule{}.applyNetwork(hostConfig)
+
+	assert.Equal(t, container.NetworkMode("host"), hostConfig.NetworkMode)
+	assert.Empty(t, networkingConfig.EndpointsConfig)
+
+	hostConfig = &container.HostConfig{}
+	networkingConfig = Rule{Network: "bridge", Links: []string{"mysql:db"}}.applyNetwork(hostConfig)
+
+	assert.Equal(t, container.NetworkMode("bridge"), hostConfig.NetworkMode)
+	assert.Equal(t, []string{"mysql:db"}, hostConfig.Links)
+	assert.Empty(t, networkingConfig.EndpointsConfig)
+
+	rule := Rule{
+		Network:        "backend",
+		NetworkAliases: []string{"backuper"},
+		Links:          []string{"mysql:db"},
+		ExtraHosts:     []string{"legacy:10.0.0.2"},
+	}
+
+	hostConfig = &container.HostConfig{}
+	networkingConfig = rule.applyNetwork(hostConfig)
+
+	assert.Equal(t, container.NetworkMode("backend"), hostConfig.NetworkMode)
+	assert.Equal(t, []string{"legacy:10.0.0.2"}, hostConfig.ExtraHosts)
+	// links of user-defined networks are configured per endpoint
+	assert.Empty(t, hostConfig.Links)
+	assert.Equal(t, map[string]*network.EndpointSettings{
+		"backend": {Aliases: []string{"backuper"}, Links: []string{"mysql:db"}},
+	}, networkingConfig.EndpointsConfig)
+}
@@ -0,0 +1,154 @@
+package domain
+
+import (
+	"context"
+	"os"
+	"path"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+const (
+	// Backup command writes its results into $BACKUP_TARGET_DIR (default)
+	OutputDirectory = "directory"
+	// Stdout of backup command is stored as a file in $BACKUP_TARGET_DIR
+	OutputStdout = "stdout"
+)
+
+const defaultOutputFile = "dump"
+
+// Stdout of running container being written to a file
+type outputCapture struct {
+	resp   types.HijackedResponse
+	stderr *tailBuffer
+
+	// Closed as soon as copying is finished, err is set before that
+	done chan struct{}
+	err  error
+}
+
+// attachOutput attaches to stdout of not yet started container and copies
+// everything it writes into output file within temp directory
+func (s *BackupService) attachOutput(ctx context.Context, rule Rule, containerId, dir string) error {
+	return s.captureOutput(rule, containerId, dir, func() (types.HijackedResponse, error) {
+		return s.docker.ContainerAttach(ctx, containerId, types.ContainerAttachOptions{
+			Stream: true,
+			Stdout: true,
+			Stderr: true,
+		})
+	})
+}
+
+// captureOutput copies stdout of the stream returned by attach into output
+// file, the capture is tracked by given id (of either container or exec)
+func (s *BackupService) captureOutput(rule Rule, id, dir string, attach func() (types.HijackedResponse, error)) error {
+	name := rule.OutputFile
+	if name == "" {
+		name = defaultOutputFile
+	}
+
+	f, err := os.Create(path.Join(dir, name))
+	if err != nil {
+		return err
+	}
+
+	resp, err := attach()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	capture := &outputCapture{
+		resp:   resp,
+		stderr: &tailBuffer{max: maxLogSize, lines: maxLogLines},
+		done:   make(chan struct{}),
+	}
+
+	go func() {
+		_, err := stdcopy.StdCopy(f, capture.stderr, resp.Reader)
+		if e := f.Close(); e != nil && err == nil {
+			err = e
+		}
+		capture.err = err
+		close(capture.done)
+	}()
+
+	s.outputsMu.Lock()
+	s.outputs[id] = capture
+	s.outputsMu.Unlock()
+
+	return nil
+}
+
+// detachOutput stops tracking output of container (or exec), the capture is returned
+// (if there is any) so the caller could wait for it to finish
+func (s *BackupService) detachOutput(id string) *outputCapture {
+	s.outputsMu.Lock()
+	defer s.outputsMu.Unlock()
+
+	capture, ok := s.outputs[id]
+	if !ok {
+		return nil
+	}
+
+	delete(s.outputs, id)
+
+	return capture
+}
+
+// wait blocks until stdout is completely written to file
+func (c *outputCapture) wait(ctx context.Context) error {
+	select {
+	case <-c.done:
+		return c.err
+	case <-ctx.Done():
+		c.close()
+		return ctx.Err()
+	}
+}
+
+// close interrupts copying (if it's still in progress) and waits for it to stop
+func (c *outputCapture) close() {
+	c.resp.Close()
+	<-c.done
+}
+
+// Keeps at most `max` last bytes written to it, String returns
+// at most `lines` last lines of them (unless it's zero)
+type tailBuffer struct {
+	max   int
+	lines int
+	buf   []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.max {
+		b.buf = b.buf[len(b.buf)-b.max:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	buf := b.buf
+
+	if b.lines > 0 {
+		n := 0
+
+		// Trailing newline ends the last line rather than starts a new one
+		for i := len(buf) - 2; i >= 0; i-- {
+			if buf[i] != '\n' {
+				continue
+			}
+
+			n++
+			if n == b.lines {
+				buf = buf[i+1:]
+				break
+			}
+		}
+	}
+
+	return string(buf)
+}
@@ -0,0 +1,264 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/yurykabanov/backuper/pkg/appcontext"
+)
+
+// StorageNames returns storages archives of the rule are transferred to
+func (r Rule) StorageNames() []string {
+	if len(r.Storages) > 0 {
+		return r.Storages
+	}
+
+	return []string{r.StorageName}
+}
+
+// quorum is the number of copies required for backup to be successful
+func (r Rule) quorum() int {
+	if r.Quorum > 0 {
+		return r.Quorum
+	}
+
+	return len(r.StorageNames())
+}
+
+// ValidateStorages checks storages the archive is replicated to
+func (r Rule) ValidateStorages() error {
+	if len(r.Storages) == 0 {
+		if r.Quorum > 1 {
+			return fmt.Errorf("quorum must not exceed number of storages")
+		}
+
+		return nil
+	}
+
+	if r.StorageName != "" {
+		return fmt.Errorf("storage_name and storages are mutually exclusive")
+	}
+
+	seen := make(map[string]bool, len(r.Storages))
+	for _, storage := range r.Storages {
+		if storage == "" {
+			return fmt.Errorf("storage name must not be empty")
+		}
+		if seen[storage] {
+			return fmt.Errorf("storage '%s' is specified more than once", storage)
+		}
+		seen[storage] = true
+	}
+
+	if r.Quorum < 0 || r.Quorum > len(r.Storages) {
+		return fmt.Errorf("quorum must be between 1 and number of storages")
+	}
+
+	// Stream can be read only once
+	if r.Streaming && len(r.Storages) > 1 {
+		return fmt.Errorf("streaming supports only single storage")
+	}
+
+	return nil
+}
+
+// transferCopies transfers archive to every storage of the rule which doesn't
+// have its copy yet, it fails unless quorum of copies is stored
+func (s *BackupService) transferCopies(ctx context.Context, rule Rule, backup Backup) (Backup, error) {
+	logger := appcontext.LoggerFromContext(s.logger, ctx)
+
+	stored := make(map[string]bool)
+
+	// Only retried transfers could have copies already
+	if backup.TransferAttempts > 0 {
+		copies, err := s.repo.FindCopies(ctx, backup.Id)
+		if err != nil {
+			return backup, err
+		}
+
+		for _, c := range copies {
+			if c.ExecStatus == ExecStatusSuccess {
+				stored[c.StorageName] = true
+			}
+		}
+	}
+
+	var failures []string
+	count := 0
+
+	for _, storage := range rule.StorageNames() {
+		if stored[storage] {
+			count++
+			continue
+		}
+
+		c := BackupCopy{BackupId: backup.Id, StorageName: storage, ExecStatus: ExecStatusSuccess, CreatedAt: time.Now()}
+
+		target := backup
+		target.StorageName = storage
+
+		file, err := s.transferManager.Transfer(target)
+		if err != nil {
+			logger.WithError(err).WithField("storage", storage).Warn("Unable to transfer backup to storage")
+
+			c.ExecStatus = ExecStatusFailure
+			failures = append(failures, fmt.Sprintf("%s: %s", storage, err))
+		} else {
+			c.BackupFile = file
+			count++
+
+			// Backup itself refers to the first stored copy
+			if backup.BackupFile == "" {
+				backup.StorageName = storage
+				backup.BackupFile = file
+			}
+		}
+
+		if err := s.repo.SaveCopy(ctx, c); err != nil {
+			logger.WithError(err).WithField("storage", storage).Error("Unable to save copy of backup")
+		}
+	}
+
+	if count < rule.quorum() {
+		return backup, fmt.Errorf("%d of %d required copies are stored: %s", count, rule.quorum(), strings.Join(failures, "; "))
+	}
+
+	return backup, nil
+}
+
+// removeCopies removes all stored copies of backup, copies which are removed
+// successfully are marked deleted so they aren't removed twice
+func (s *BackupService) removeCopies(ctx context.Context, backup Backup) error {
+	copies, err := s.repo.FindCopies(ctx, backup.Id)
+	if err != nil {
+		return err
+	}
+
+	// Backups made before replication have no copies
+	if len(copies) == 0 {
+		return s.transferManager.Remove(backup)
+	}
+
+	var failures []string
+
+	for _, c := range copies {
+		if c.ExecStatus != ExecStatusSuccess || c.DeletedAt != nil {
+			continue
+		}
+
+		target := backup
+		target.StorageName = c.StorageName
+		target.BackupFile = c.BackupFile
+
+		err := s.transferManager.Remove(target)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", c.StorageName, err))
+			continue
+		}
+
+		now := time.Now()
+		c.DeletedAt = &now
+
+		err = s.repo.SaveCopy(ctx, c)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("unable to remove copies: %s", strings.Join(failures, "; "))
+	}
+
+	return nil
+}
+
+// openCopy opens the first available copy of backup archive
+func (s *BackupService) openCopy(ctx context.Context, backup Backup) (io.ReadCloser, error) {
+	copies, err := s.repo.FindCopies(ctx, backup.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(copies) == 0 {
+		return s.transferManager.Open(backup)
+	}
+
+	var failures []string
+
+	for _, c := range copies {
+		if c.ExecStatus != ExecStatusSuccess || c.DeletedAt != nil {
+			continue
+		}
+
+		target := backup
+		target.StorageName = c.StorageName
+		target.BackupFile = c.BackupFile
+
+		r, err := s.transferManager.Open(target)
+		if err == nil {
+			return r, nil
+		}
+
+		failures = append(failures, fmt.Sprintf("%s: %s", c.StorageName, err))
+	}
+
+	if len(failures) == 0 {
+		return nil, errors.New("backup has no stored copies")
+	}
+
+	return nil, fmt.Errorf("unable to open any copy: %s", strings.Join(failures, "; "))
+}
+
+// DeleteCopy removes copy of backup from its storage (backup is as it's stored
+// there, see BackupRepository.FindAllSuccessfulNotDeletedCopies), backup itself
+// is marked deleted once none of its copies are left
+func (s *BackupService) DeleteCopy(ctx context.Context, backup Backup) error {
+	err := s.transferManager.Remove(backup)
+	if err != nil {
+		return err
+	}
+
+	copies, err := s.repo.FindCopies(ctx, backup.Id)
+	if err != nil {
+		return err
+	}
+
+	left := 0
+
+	for _, c := range copies {
+		if c.StorageName == backup.StorageName {
+			now := time.Now()
+			c.DeletedAt = &now
+
+			err = s.repo.SaveCopy(ctx, c)
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if c.ExecStatus == ExecStatusSuccess && c.DeletedAt == nil {
+			left++
+		}
+	}
+
+	if left > 0 {
+		return nil
+	}
+
+	original, err := s.repo.FindById(ctx, backup.Id)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	original.DeletedAt = &now
+
+	return s.repo.Update(ctx, original)
+}
@@ -0,0 +1,219 @@
+package domain
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRule_ValidateStorages(t *testing.T) {
+	assert.Nil(t, Rule{StorageName: "local"}.ValidateStorages())
+	assert.Nil(t, Rule{Storages: []string{"local", "s3"}}.ValidateStorages())
+	assert.Nil(t, Rule{Storages: []string{"local", "s3"}, Quorum: 1}.ValidateStorages())
+
+	assert.NotNil(t, Rule{StorageName: "local", Storages: []string{"s3"}}.ValidateStorages())
+	assert.NotNil(t, Rule{Storages: []string{"local", "local"}}.ValidateStorages())
+	assert.NotNil(t, Rule{Storages: []string{"local", ""}}.ValidateStorages())
+	assert.NotNil(t, Rule{Storages: []string{"local", "s3"}, Quorum: 3}.ValidateStorages())
+	assert.NotNil(t, Rule{StorageName: "local", Quorum: 2}.ValidateStorages())
+	assert.NotNil(t, Rule{Storages: []string{"local", "s3"}, Streaming: true}.ValidateStorages())
+}
+
+func TestRule_StorageNames(t *testing.T) {
+	assert.Equal(t, []string{"local"}, Rule{StorageName: "local"}.StorageNames())
+	assert.Equal(t, []string{"local", "s3"}, Rule{Storages: []string{"local", "s3"}}.StorageNames())
+
+	assert.Equal(t, 2, Rule{Storages: []string{"local", "s3"}}.quorum())
+	assert.Equal(t, 1, Rule{Storages: []string{"local", "s3"}, Quorum: 1}.quorum())
+}
+
+func storageIs(name string) interface{} {
+	return mock.MatchedBy(func(b Backup) bool { return b.StorageName == name })
+}
+
+func TestService_transferCopies(t *testing.T) {
+	repo := &backupRepositoryMock{}
+	transferManager := &transferManagerMock{}
+
+	ctx := context.Background()
+	backup := Backup{Id: 42, Rule: "some-rule", StorageName: "local", TempBackupFile: "/tmp/some-dir/__backup__.zip"}
+
+	transferManager.On("Transfer", storageIs("local")).Return("", errors.New("disk is full")).Once()
+	transferManager.On("Transfer", storageIs("s3")).Return("/bucket/some_file.zip", nil).Once()
+
+	repo.On("SaveCopy", ctx, mock.MatchedBy(func(c BackupCopy) bool {
+		return c.StorageName == "local" && c.ExecStatus == ExecStatusFailure
+	})).Return(nil).Once()
+	repo.On("SaveCopy", ctx, mock.MatchedBy(func(c BackupCopy) bool {
+		return c.StorageName == "s3" && c.ExecStatus == ExecStatusSuccess && c.BackupFile == "/bucket/some_file.zip"
+	})).Return(nil).Once()
+
+	svc := NewBackupService(discardLogger(), repo, nil, nil, transferManager, nil, nil)
+
+	rule := Rule{Name: "some-rule", Storages: []string{"local", "s3"}, Quorum: 1}
+
+	result, err := svc.transferCopies(ctx, rule, backup)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "s3", result.StorageName)
+	assert.Equal(t, "/bucket/some_file.zip", result.BackupFile)
+	repo.AssertExpectations(t)
+	transferManager.AssertExpectations(t)
+}
+
+func TestService_transferCopies_QuorumNotReached(t *testing.T) {
+	repo := &backupRepositoryMock{}
+	transferManager := &transferManagerMock{}
+
+	ctx := context.Background()
+	backup := Backup{Id: 42, Rule: "some-rule", StorageName: "local"}
+	rule := Rule{Name: "some-rule", Storages: []string{"local", "s3"}}
+
+	transferManager.On("Transfer", storageIs("local")).Return("/backups/some_file.zip", nil).Once()
+	transferManager.On("Transfer", storageIs("s3")).Return("", errors.New("timeout")).Once()
+	repo.On("SaveCopy", ctx, mock.AnythingOfType("BackupCopy")).Return(nil).Twice()
+
+	svc := NewBackupService(discardLogger(), repo, nil, nil, transferManager, nil, nil)
+
+	backup, err := svc.transferCopies(ctx, rule, backup)
+
+	assert.NotNil(t, err)
+	assert.Equal(t, "/backups/some_file.zip", backup.BackupFile)
+
+	// retry transfers only missing copy
+	backup.TransferAttempts = 1
+
+	repo.On("FindCopies", ctx, backup.Id).Return([]BackupCopy{
+		{BackupId: 42, StorageName: "local", ExecStatus: ExecStatusSuccess, BackupFile: "/backups/some_file.zip"},
+		{BackupId: 42, StorageName: "s3", ExecStatus: ExecStatusFailure},
+	}, nil)
+	transferManager.On("Transfer", storageIs("s3")).Return("/bucket/some_file.zip", nil).Once()
+	repo.On("SaveCopy", ctx, mock.MatchedBy(func(c BackupCopy) bool {
+		return c.StorageName == "s3" && c.ExecStatus == ExecStatusSuccess
+	})).Return(nil).Once()
+
+	backup, err = svc.transferCopies(ctx, rule, backup)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "local", backup.StorageName)
+	repo.AssertExpectations(t)
+	transferManager.AssertExpectations(t)
+}
+
+func TestService_DeleteBackup_Copies(t *testing.T) {
+	repo := &backupRepositoryMock{}
+	transferManager := &transferManagerMock{}
+
+	ctx := context.Background()
+	deletedAt := time.Now()
+	backup := Backup{Id: 42, Rule: "some-rule", StorageName: "local", BackupFile: "/backups/some_file.zip"}
+
+	repo.On("FindCopies", ctx, backup.Id).Return([]BackupCopy{
+		{BackupId: 42, StorageName: "local", ExecStatus: ExecStatusSuccess, BackupFile: "/backups/some_file.zip"},
+		{BackupId: 42, StorageName: "s3", ExecStatus: ExecStatusSuccess, BackupFile: "/bucket/some_file.zip"},
+		{BackupId: 42, StorageName: "sftp", ExecStatus: ExecStatusFailure},
+		{BackupId: 42, StorageName: "webdav", ExecStatus: ExecStatusSuccess, BackupFile: "/dav/some_file.zip", DeletedAt: &deletedAt},
+	}, nil)
+
+	transferManager.On("Remove", mock.MatchedBy(func(b Backup) bool {
+		return b.StorageName == "local" && b.BackupFile == "/backups/some_file.zip"
+	})).Return(nil).Once()
+	transferManager.On("Remove", mock.MatchedBy(func(b Backup) bool {
+		return b.StorageName == "s3" && b.BackupFile == "/bucket/some_file.zip"
+	})).Return(nil).Once()
+
+	repo.On("SaveCopy", ctx, mock.MatchedBy(func(c BackupCopy) bool {
+		return c.DeletedAt != nil
+	})).Return(nil).Twice()
+	repo.On("Update", ctx, mock.MatchedBy(func(b Backup) bool {
+		return b.DeletedAt != nil
+	})).Return(nil).Once()
+
+	svc := NewBackupService(discardLogger(), repo, nil, nil, transferManager, nil, nil)
+
+	err := svc.DeleteBackup(ctx, backup)
+
+	assert.Nil(t, err)
+	repo.AssertExpectations(t)
+	transferManager.AssertExpectations(t)
+}
+
+func TestService_openCopy_Fallback(t *testing.T) {
+	repo := &backupRepositoryMock{}
+	transferManager := &transferManagerMock{}
+
+	ctx := context.Background()
+	backup := Backup{Id: 42, Rule: "some-rule", StorageName: "local", BackupFile: "/backups/some_file.zip"}
+
+	repo.On("FindCopies", ctx, backup.Id).Return([]BackupCopy{
+		{BackupId: 42, StorageName: "local", ExecStatus: ExecStatusSuccess, BackupFile: "/backups/some_file.zip"},
+		{BackupId: 42, StorageName: "s3", ExecStatus: ExecStatusSuccess, BackupFile: "/bucket/some_file.zip"},
+	}, nil)
+
+	transferManager.On("Open", storageIs("local")).Return(nil, errors.New("no such file"))
+	transferManager.On("Open", storageIs("s3")).Return(ioutil.NopCloser(bytes.NewReader([]byte("some archive"))), nil)
+
+	svc := NewBackupService(discardLogger(), repo, nil, nil, transferManager, nil, nil)
+
+	r, err := svc.openCopy(ctx, backup)
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer r.Close()
+
+	data, _ := ioutil.ReadAll(r)
+	assert.Equal(t, "some archive", string(data))
+}
+
+func TestService_DeleteCopy(t *testing.T) {
+	repo := &backupRepositoryMock{}
+	transferManager := &transferManagerMock{}
+
+	ctx := context.Background()
+
+	// copy in s3 as returned by FindAllSuccessfulNotDeletedCopies
+	backup := Backup{Id: 42, Rule: "some-rule", StorageName: "s3", BackupFile: "/bucket/some_file.zip"}
+
+	copies := []BackupCopy{
+		{BackupId: 42, StorageName: "local", ExecStatus: ExecStatusSuccess, BackupFile: "/backups/some_file.zip"},
+		{BackupId: 42, StorageName: "s3", ExecStatus: ExecStatusSuccess, BackupFile: "/bucket/some_file.zip", Generation: 1},
+	}
+
+	transferManager.On("Remove", backup).Return(nil).Once()
+	repo.On("FindCopies", ctx, backup.Id).Return(copies, nil).Once()
+	repo.On("SaveCopy", ctx, mock.MatchedBy(func(c BackupCopy) bool {
+		return c.StorageName == "s3" && c.Generation == 1 && c.DeletedAt != nil
+	})).Return(nil).Once()
+
+	svc := NewBackupService(discardLogger(), repo, nil, nil, transferManager, nil, nil)
+
+	// copy in local storage is left, so backup isn't deleted
+	assert.Nil(t, svc.DeleteCopy(ctx, backup))
+	repo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+
+	// the last copy is deleted along with backup
+	deletedAt := time.Now()
+	copies[1].DeletedAt = &deletedAt
+	local := Backup{Id: 42, Rule: "some-rule", StorageName: "local", BackupFile: "/backups/some_file.zip"}
+
+	transferManager.On("Remove", local).Return(nil).Once()
+	repo.On("FindCopies", ctx, backup.Id).Return(copies, nil).Once()
+	repo.On("SaveCopy", ctx, mock.MatchedBy(func(c BackupCopy) bool {
+		return c.StorageName == "local" && c.DeletedAt != nil
+	})).Return(nil).Once()
+	repo.On("FindById", ctx, backup.Id).Return(local, nil).Once()
+	repo.On("Update", ctx, mock.MatchedBy(func(b Backup) bool {
+		return b.Id == 42 && b.DeletedAt != nil
+	})).Return(nil).Once()
+
+	assert.Nil(t, svc.DeleteCopy(ctx, local))
+
+	repo.AssertExpectations(t)
+	transferManager.AssertExpectations(t)
+}
@@ -0,0 +1,84 @@
+package domain
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// Multipliers of memory size suffixes as accepted by `docker run --memory`
+var memoryUnits = map[string]int64{
+	"":  1,
+	"b": 1,
+	"k": 1 << 10,
+	"m": 1 << 20,
+	"g": 1 << 30,
+}
+
+// parseMemory parses sizes like "512m" or "1g" into bytes
+func parseMemory(size string) (int64, error) {
+	s := strings.ToLower(strings.TrimSpace(size))
+	s = strings.TrimSuffix(s, "b")
+
+	unit := ""
+	if n := len(s); n > 0 && (s[n-1] < '0' || s[n-1] > '9') {
+		unit, s = s[n-1:], s[:n-1]
+	}
+
+	multiplier, ok := memoryUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("invalid memory size '%s'", size)
+	}
+
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil || value <= 0 {
+		return 0, fmt.Errorf("invalid memory size '%s'", size)
+	}
+
+	return int64(value * float64(multiplier)), nil
+}
+
+// ValidateResources checks limits and security options of dumper container
+func (r Rule) ValidateResources() error {
+	if r.CPUs < 0 {
+		return fmt.Errorf("cpus must not be negative")
+	}
+
+	if r.Memory != "" {
+		if _, err := parseMemory(r.Memory); err != nil {
+			return err
+		}
+	}
+
+	for _, opt := range r.SecurityOpt {
+		if opt == "" {
+			return fmt.Errorf("security_opt must not be empty")
+		}
+	}
+
+	return nil
+}
+
+// applyResources sets limits and security options of dumper container
+func (r Rule) applyResources(config *container.Config, hostConfig *container.HostConfig) error {
+	if r.Memory != "" {
+		memory, err := parseMemory(r.Memory)
+		if err != nil {
+			return err
+		}
+
+		hostConfig.Memory = memory
+	}
+
+	hostConfig.NanoCPUs = int64(r.CPUs * 1e9)
+	hostConfig.ReadonlyRootfs = r.ReadOnly
+	hostConfig.CapDrop = r.CapDrop
+	hostConfig.SecurityOpt = r.SecurityOpt
+
+	config.User = r.User
+	config.WorkingDir = r.WorkingDir
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/strslice"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMemory(t *testing.T) {
+	cases := map[string]int64{
+		"1024":  1024,
+		"512b":  512,
+		"64k":   64 << 10,
+		"512m":  512 << 20,
+		"512MB": 512 << 20,
+		"1.5g":  3 << 29,
+	}
+
+	for size, expected := range cases {
+		bytes, err := parseMemory(size)
+		assert.Nil(t, err, size)
+		assert.Equal(t, expected, bytes, size)
+	}
+
+	for _, size := range []string{"", "m", "-1m", "512x", "lots"} {
+		_, err := parseMemory(size)
+		assert.NotNil(t, err, size)
+	}
+}
+
+func TestRule_ValidateResources(t *testing.T) {
+	valid := []Rule{
+		{},
+		{CPUs: 0.5, Memory: "512m", SecurityOpt: []string{"no-new-privileges"}},
+	}
+
+	for _, rule := range valid {
+		assert.Nil(t, rule.ValidateResources(), "%+v", rule)
+	}
+
+	invalid := []Rule{
+		{CPUs: -1},
+		{Memory: "a lot"},
+		{SecurityOpt: []string{""}},
+	}
+
+	for _, rule := range invalid {
+		assert.NotNil(t, rule.ValidateResources(), "%+v", rule)
+	}
+}
+
+func TestRule_applyResources(t *testing.T) {
+	rule := Rule{
+		CPUs:        0.5,
+		Memory:      "256m",
+		User:        "999:999",
+		WorkingDir:  "/work",
+		ReadOnly:    true,
+		CapDrop:     []string{"ALL"},
+		SecurityOpt: []string{"no-new-privileges"},
+	}
+
+	config := &container.Config{}
+	hostConfig := &container.HostConfig{}
+
+	assert.Nil(t, rule.applyResources(config, hostConfig))
+
+	assert.Equal(t, int64(5e8), hostConfig.NanoCPUs)
+	assert.Equal(t, int64(256<<20), hostConfig.Memory)
+	assert.True(t, hostConfig.ReadonlyRootfs)
+	assert.Equal(t, strslice.StrSlice{"ALL"}, hostConfig.CapDrop)
+	assert.Equal(t, []string{"no-new-privileges"}, hostConfig.SecurityOpt)
+	assert.Equal(t, "999:999", config.User)
+	assert.Equal(t, "/work", config.WorkingDir)
+}
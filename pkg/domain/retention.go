@@ -0,0 +1,144 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/yurykabanov/backuper/pkg/appcontext"
+)
+
+// Calendar-aligned (grandfather-father-son) retention: the newest backup of
+// each of the last Daily days, Weekly ISO weeks, Monthly months and Yearly
+// years is kept along with Last backups, calendar is in Timezone (UTC by default)
+type GFSRetention struct {
+	Last     int    `mapstructure:"last"`
+	Daily    int    `mapstructure:"daily"`
+	Weekly   int    `mapstructure:"weekly"`
+	Monthly  int    `mapstructure:"monthly"`
+	Yearly   int    `mapstructure:"yearly"`
+	Timezone string `mapstructure:"timezone"`
+}
+
+func (p GFSRetention) Validate() error {
+	if p.Last < 0 || p.Daily < 0 || p.Weekly < 0 || p.Monthly < 0 || p.Yearly < 0 {
+		return fmt.Errorf("retention counts must not be negative")
+	}
+
+	if p.Last+p.Daily+p.Weekly+p.Monthly+p.Yearly == 0 {
+		return fmt.Errorf("retention must keep at least one backup")
+	}
+
+	if _, err := time.LoadLocation(p.Timezone); err != nil {
+		return fmt.Errorf("invalid retention timezone '%s': %s", p.Timezone, err)
+	}
+
+	return nil
+}
+
+// Calendar buckets backups are grouped by
+var retentionBuckets = []struct {
+	count func(GFSRetention) int
+	key   func(time.Time) string
+}{
+	{
+		count: func(p GFSRetention) int { return p.Daily },
+		key:   func(t time.Time) string { return t.Format("2006-01-02") },
+	},
+	{
+		count: func(p GFSRetention) int { return p.Weekly },
+		key: func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		},
+	},
+	{
+		count: func(p GFSRetention) int { return p.Monthly },
+		key:   func(t time.Time) string { return t.Format("2006-01") },
+	},
+	{
+		count: func(p GFSRetention) int { return p.Yearly },
+		key:   func(t time.Time) string { return t.Format("2006") },
+	},
+}
+
+// keep returns ids of backups retained by the policy
+func (p GFSRetention) keep(backups []Backup) map[int64]bool {
+	// Timezone has been validated already, UTC is used for empty one
+	loc, err := time.LoadLocation(p.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	newest := make([]Backup, len(backups))
+	copy(newest, backups)
+	sort.SliceStable(newest, func(i, j int) bool { return newest[i].CreatedAt.After(newest[j].CreatedAt) })
+
+	kept := make(map[int64]bool)
+
+	for i := 0; i < p.Last && i < len(newest); i++ {
+		kept[newest[i].Id] = true
+	}
+
+	for _, bucket := range retentionBuckets {
+		count := bucket.count(p)
+		seen := make(map[string]bool, count)
+
+		for _, b := range newest {
+			if len(seen) >= count {
+				break
+			}
+
+			key := bucket.key(b.CreatedAt.In(loc))
+			if seen[key] {
+				continue
+			}
+
+			seen[key] = true
+			kept[b.Id] = true
+		}
+	}
+
+	return kept
+}
+
+// retention returns calendar-aligned retention of copies stored in given
+// storage or nil if they're rotated according to rotation rules
+func (r Rule) retention(storage string) *GFSRetention {
+	for _, sr := range r.StorageRotation {
+		if sr.Storage == storage {
+			return sr.Retention
+		}
+	}
+
+	return r.Retention
+}
+
+// sweepRetention deletes copies stored in given storage which aren't kept by
+// calendar-aligned retention, unlike rotation rules it doesn't depend on the
+// order backups were made in, so generations aren't used
+func (m *BackupManager) sweepRetention(ctx context.Context, rule Rule, storage string, retention GFSRetention) {
+	logger := appcontext.LoggerFromContext(m.logger, ctx).WithField("storage", storage)
+
+	backups, err := m.repo.FindAllSuccessfulNotDeletedCopies(ctx, rule, storage)
+	if err != nil {
+		logger.WithError(err).Error("Unable to query old backups")
+		return
+	}
+
+	kept := retention.keep(backups)
+
+	for _, backup := range backups {
+		if kept[backup.Id] {
+			continue
+		}
+
+		logger.Infof("Discarding backup id=%d as it isn't kept by retention", backup.Id)
+
+		err = m.service.DeleteCopy(appcontext.WithBackupId(ctx, backup.Id), backup)
+		if err != nil {
+			logger.WithError(err).Error("Unable to delete backup")
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package domain
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestGFSRetention_Validate(t *testing.T) {
+	assert.Nil(t, GFSRetention{Last: 3}.Validate())
+	assert.Nil(t, GFSRetention{Daily: 7, Weekly: 4, Monthly: 12, Yearly: 3, Timezone: "Europe/Moscow"}.Validate())
+
+	assert.NotNil(t, GFSRetention{}.Validate())
+	assert.NotNil(t, GFSRetention{Daily: -1, Weekly: 4}.Validate())
+	assert.NotNil(t, GFSRetention{Daily: 7, Timezone: "Mars/Olympus"}.Validate())
+}
+
+func TestRule_ValidateRotation_Retention(t *testing.T) {
+	retention := &GFSRetention{Daily: 7}
+	rotation := []RotationRule{{Period: time.Hour, PreserveAtMost: 24}}
+
+	assert.Nil(t, Rule{StorageName: "local", Retention: retention}.ValidateRotation())
+	assert.Nil(t, Rule{
+		Storages:        []string{"local", "s3"},
+		RotationRules:   rotation,
+		StorageRotation: []StorageRotation{{Storage: "s3", Retention: retention}},
+	}.ValidateRotation())
+
+	assert.NotNil(t, Rule{StorageName: "local", RotationRules: rotation, Retention: retention}.ValidateRotation())
+	assert.NotNil(t, Rule{StorageName: "local", Retention: &GFSRetention{}}.ValidateRotation())
+	assert.NotNil(t, Rule{
+		StorageName:     "local",
+		StorageRotation: []StorageRotation{{Storage: "local", RotationRules: rotation, Retention: retention}},
+	}.ValidateRotation())
+}
+
+func TestRule_retention(t *testing.T) {
+	daily := &GFSRetention{Daily: 7}
+	monthly := &GFSRetention{Monthly: 12}
+
+	rule := Rule{
+		Storages:  []string{"local", "s3", "sftp"},
+		Retention: daily,
+		StorageRotation: []StorageRotation{
+			{Storage: "s3", Retention: monthly},
+			{Storage: "sftp", RotationRules: []RotationRule{{Period: time.Hour, PreserveAtMost: 1}}},
+		},
+	}
+
+	assert.Equal(t, daily, rule.retention("local"))
+	assert.Equal(t, monthly, rule.retention("s3"))
+	assert.Nil(t, rule.retention("sftp"))
+}
+
+func at(value string) time.Time {
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestGFSRetention_keep(t *testing.T) {
+	backups := []Backup{
+		{Id: 1, CreatedAt: at("2018-12-31T12:00:00Z")},
+		{Id: 2, CreatedAt: at("2019-01-30T12:00:00Z")},
+		{Id: 3, CreatedAt: at("2019-02-03T12:00:00Z")}, // Sunday, ISO week 5
+		{Id: 4, CreatedAt: at("2019-02-04T06:00:00Z")}, // Monday, ISO week 6
+		{Id: 5, CreatedAt: at("2019-02-04T22:00:00Z")},
+		{Id: 6, CreatedAt: at("2019-02-05T12:00:00Z")},
+	}
+
+	assert.Equal(t, map[int64]bool{6: true, 5: true}, GFSRetention{Last: 2}.keep(backups))
+	assert.Equal(t, map[int64]bool{6: true, 5: true, 3: true}, GFSRetention{Daily: 3}.keep(backups))
+	assert.Equal(t, map[int64]bool{6: true, 3: true}, GFSRetention{Weekly: 2}.keep(backups))
+	assert.Equal(t, map[int64]bool{6: true, 2: true, 1: true}, GFSRetention{Monthly: 5}.keep(backups))
+	assert.Equal(t, map[int64]bool{6: true, 1: true}, GFSRetention{Yearly: 2}.keep(backups))
+
+	// buckets are combined
+	assert.Equal(t, map[int64]bool{6: true, 5: true, 2: true}, GFSRetention{Last: 1, Daily: 2, Monthly: 2}.keep(backups))
+
+	// 2019-02-04T22:00Z is already the next day in Moscow
+	assert.Equal(t, map[int64]bool{6: true, 4: true}, GFSRetention{Daily: 2, Timezone: "Europe/Moscow"}.keep(backups))
+}
+
+func TestManager_sweepOldBackups_Retention(t *testing.T) {
+	repo := &backupRepositoryMock{}
+	service := &backupServiceMock{}
+
+	rule := Rule{
+		Name:        "some-rule",
+		StorageName: "local",
+		Retention:   &GFSRetention{Daily: 1},
+	}
+
+	backups := []Backup{
+		{Id: 1, Rule: rule.Name, StorageName: "local", CreatedAt: at("2019-02-04T06:00:00Z")},
+		{Id: 2, Rule: rule.Name, StorageName: "local", CreatedAt: at("2019-02-04T18:00:00Z")},
+	}
+
+	repo.On("FindAllSuccessfulNotDeletedCopies", mock.Anything, rule, "local").Return(backups, nil)
+	service.On("DeleteCopy", mock.Anything, backups[0]).Return(nil).Once()
+
+	m := NewBackupManager(discardLogger(), []Rule{rule}, service, repo, nil)
+
+	m.sweepOldBackups(context.Background(), rule)
+
+	repo.AssertExpectations(t)
+	service.AssertExpectations(t)
+	repo.AssertNotCalled(t, "UpdateCopyGeneration", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
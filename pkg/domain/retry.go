@@ -0,0 +1,153 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/yurykabanov/backuper/pkg/appcontext"
+)
+
+const (
+	defaultRetryInitialDelay     = time.Minute
+	defaultRetryMultiplier       = 2
+	defaultRetryTransferAttempts = 3
+)
+
+// Failed backups are retried at most Attempts times, delay before each retry
+// grows from InitialDelay by Multiplier up to MaxDelay (unlimited if zero).
+// Failed transfers of dumped backups are retried TransferAttempts times
+// (Attempts or 3 by default) even if backups themselves aren't retried.
+type RetryPolicy struct {
+	Attempts         int           `mapstructure:"attempts"`
+	TransferAttempts int           `mapstructure:"transfer_attempts"`
+	InitialDelay     time.Duration `mapstructure:"initial_delay"`
+	MaxDelay         time.Duration `mapstructure:"max_delay"`
+	Multiplier       float64       `mapstructure:"multiplier"`
+}
+
+func (p RetryPolicy) Validate() error {
+	if p.Attempts < 0 || p.TransferAttempts < 0 {
+		return fmt.Errorf("retry attempts must not be negative")
+	}
+
+	if p.InitialDelay < 0 || p.MaxDelay < 0 {
+		return fmt.Errorf("retry delays must not be negative")
+	}
+
+	if p.Multiplier != 0 && p.Multiplier < 1 {
+		return fmt.Errorf("retry multiplier must be at least 1")
+	}
+
+	return nil
+}
+
+// transferAttempts is the number of retries of failed transfer of dumped backup
+func (p RetryPolicy) transferAttempts() int {
+	if p.TransferAttempts > 0 {
+		return p.TransferAttempts
+	}
+
+	if p.Attempts > 0 {
+		return p.Attempts
+	}
+
+	return defaultRetryTransferAttempts
+}
+
+// Delay before retrying backup which is the given attempt (0 for original backup)
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	initial := p.InitialDelay
+	if initial == 0 {
+		initial = defaultRetryInitialDelay
+	}
+
+	multiplier := p.Multiplier
+	if multiplier == 0 {
+		multiplier = defaultRetryMultiplier
+	}
+
+	delay := float64(initial) * math.Pow(multiplier, float64(attempt))
+
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		return p.MaxDelay
+	}
+
+	// Overflow protection for large number of attempts
+	if delay > math.MaxInt64 {
+		return time.Duration(math.MaxInt64)
+	}
+
+	return time.Duration(delay)
+}
+
+// scheduleRetry persists new attempt of failed backup postponed by delay of the
+// retry policy unless attempts are exhausted, dumped backups are postponed
+// and enqueued again to retry transfer only
+func (m *BackupManager) scheduleRetry(ctx context.Context, rule Rule, backup Backup) {
+	logger := appcontext.LoggerFromContext(m.logger, ctx)
+
+	if backup.ExecStatus == ExecStatusDumped {
+		delay := rule.Retry.Delay(backup.TransferAttempts)
+
+		retryAt := time.Now().Add(delay)
+		backup.RetryAt = &retryAt
+
+		// Backup is resumed after restart anyway, so it's postponed even if it can't be saved
+		if err := m.repo.Update(ctx, backup); err != nil {
+			logger.WithError(err).Error("Unable to postpone transfer of backup")
+		}
+
+		logger.WithField("delay", delay.String()).Info("Unable to transfer backup, scheduling retry of transfer")
+
+		go m.enqueueAt(context.Background(), backup)
+		return
+	}
+
+	// Dump was successful, it's not repeated when retries of its transfer are exhausted
+	if backup.TransferAttempts > 0 {
+		logger.Warn("Unable to transfer backup, no retry attempts left")
+		return
+	}
+
+	if backup.Attempt >= rule.Retry.Attempts {
+		if rule.Retry.Attempts > 0 {
+			logger.Warn("Backup has failed, no retry attempts left")
+		}
+		return
+	}
+
+	delay := rule.Retry.Delay(backup.Attempt)
+
+	retry, err := m.createRetry(ctx, rule, backup, time.Now().Add(delay))
+	if err != nil {
+		logger.WithError(err).Error("Unable to create retry of backup")
+		return
+	}
+
+	logger.WithFields(logrus.Fields{"delay": delay.String(), "retry_backup_id": retry.Id}).Info("Backup has failed, scheduling retry")
+
+	go m.enqueueAt(context.Background(), retry)
+}
+
+// createRetry creates new attempt of failed backup linked to the original one,
+// it's resumed after restart as any other unfinished backup
+func (m *BackupManager) createRetry(ctx context.Context, rule Rule, failed Backup, at time.Time) (Backup, error) {
+	original := failed.Id
+	if failed.RetryOf != nil {
+		original = *failed.RetryOf
+	}
+
+	return m.repo.Create(ctx, Backup{
+		Rule:        rule.Name,
+		ExecStatus:  ExecStatusNew,
+		CreatedAt:   time.Now(),
+		StorageName: rule.StorageNames()[0],
+		RetryOf:     &original,
+		Attempt:     failed.Attempt + 1,
+		RetryAt:     &at,
+	})
+}
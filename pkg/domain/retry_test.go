@@ -0,0 +1,166 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// region backupServiceMock
+type backupServiceMock struct {
+	mock.Mock
+}
+
+func (m *backupServiceMock) StartBackup(ctx context.Context, rule Rule, backup Backup) (Backup, error) {
+	args := m.Called(ctx, rule, backup)
+	return args.Get(0).(Backup), args.Error(1)
+}
+
+func (m *backupServiceMock) FinishBackup(ctx context.Context, rule Rule, backup Backup) (Backup, error) {
+	args := m.Called(ctx, rule, backup)
+	return args.Get(0).(Backup), args.Error(1)
+}
+
+func (m *backupServiceMock) TransferBackup(ctx context.Context, rule Rule, backup Backup) (Backup, error) {
+	args := m.Called(ctx, rule, backup)
+	return args.Get(0).(Backup), args.Error(1)
+}
+
+func (m *backupServiceMock) AbortBackup(ctx context.Context, backup Backup) error {
+	args := m.Called(ctx, backup)
+	return args.Error(0)
+}
+
+func (m *backupServiceMock) DeleteBackup(ctx context.Context, backup Backup) error {
+	args := m.Called(ctx, backup)
+	return args.Error(0)
+}
+
+func (m *backupServiceMock) DeleteCopy(ctx context.Context, backup Backup) error {
+	args := m.Called(ctx, backup)
+	return args.Error(0)
+}
+
+func (m *backupServiceMock) RestoreBackup(ctx context.Context, rule Rule, backup Backup) error {
+	args := m.Called(ctx, rule, backup)
+	return args.Error(0)
+}
+
+// endregion
+
+func TestRetryPolicy_Delay(t *testing.T) {
+	defaults := RetryPolicy{Attempts: 3}
+
+	assert.Equal(t, time.Minute, defaults.Delay(0))
+	assert.Equal(t, 2*time.Minute, defaults.Delay(1))
+	assert.Equal(t, 4*time.Minute, defaults.Delay(2))
+
+	policy := RetryPolicy{Attempts: 5, InitialDelay: 10 * time.Second, MaxDelay: time.Minute, Multiplier: 3}
+
+	assert.Equal(t, 10*time.Second, policy.Delay(0))
+	assert.Equal(t, 30*time.Second, policy.Delay(1))
+	assert.Equal(t, time.Minute, policy.Delay(2))
+	assert.Equal(t, time.Minute, policy.Delay(100))
+}
+
+func TestRetryPolicy_transferAttempts(t *testing.T) {
+	assert.Equal(t, 3, RetryPolicy{}.transferAttempts())
+	assert.Equal(t, 2, RetryPolicy{Attempts: 2}.transferAttempts())
+	assert.Equal(t, 5, RetryPolicy{Attempts: 2, TransferAttempts: 5}.transferAttempts())
+}
+
+func TestRetryPolicy_Validate(t *testing.T) {
+	assert.Nil(t, RetryPolicy{}.Validate())
+	assert.Nil(t, RetryPolicy{Attempts: 3, InitialDelay: time.Minute, MaxDelay: time.Hour, Multiplier: 1.5}.Validate())
+
+	assert.NotNil(t, RetryPolicy{Attempts: -1}.Validate())
+	assert.NotNil(t, RetryPolicy{TransferAttempts: -1}.Validate())
+	assert.NotNil(t, RetryPolicy{Attempts: 1, InitialDelay: -time.Second}.Validate())
+	assert.NotNil(t, RetryPolicy{Attempts: 1, Multiplier: 0.5}.Validate())
+}
+
+func TestManager_handleRuleBackup_Retry(t *testing.T) {
+	repo := &backupRepositoryMock{}
+	service := &backupServiceMock{}
+
+	rule := Rule{
+		Name:        "some-rule",
+		StorageName: "some-storage",
+		Timeout:     time.Minute,
+		Retry:       RetryPolicy{Attempts: 2, InitialDelay: time.Millisecond},
+	}
+
+	originalId := int64(40)
+	failed := Backup{Id: 42, Rule: rule.Name, ExecStatus: ExecStatusStarted, RetryOf: &originalId, Attempt: 1, CreatedAt: time.Now()}
+
+	service.On("FinishBackup", mock.Anything, rule, failed).Return(failed, errors.New("unable to transfer"))
+	repo.On("FindAllSuccessfulNotDeletedCopies", mock.Anything, rule, "some-storage").Return([]Backup{}, nil)
+	repo.On("Create", mock.Anything, mock.MatchedBy(func(b Backup) bool {
+		return b.ExecStatus == ExecStatusNew && b.StorageName == "some-storage" &&
+			b.RetryOf != nil && *b.RetryOf == originalId && b.Attempt == 2 && b.RetryAt != nil
+	})).Return(Backup{Id: 43, Rule: rule.Name, RetryOf: &originalId, Attempt: 2}, nil).Once()
+
+	m := NewBackupManager(discardLogger(), []Rule{rule}, service, repo, nil)
+
+	m.handleRuleBackup(context.Background(), rule, failed)
+
+	select {
+	case retry := <-m.active[rule.Name]:
+		assert.Equal(t, int64(43), retry.Id)
+	case <-time.After(time.Second):
+		t.Fatal("retry has not been dispatched")
+	}
+
+	// the last attempt isn't retried
+	last := Backup{Id: 43, Rule: rule.Name, ExecStatus: ExecStatusStarted, RetryOf: &originalId, Attempt: 2, CreatedAt: time.Now()}
+	service.On("FinishBackup", mock.Anything, rule, last).Return(last, errors.New("unable to transfer"))
+
+	m.handleRuleBackup(context.Background(), rule, last)
+
+	select {
+	case <-m.active[rule.Name]:
+		t.Fatal("exhausted backup has been retried")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	repo.AssertExpectations(t)
+}
+
+func TestManager_enqueueAt(t *testing.T) {
+	rule := Rule{Name: "some-rule", StorageName: "some-storage"}
+
+	m := NewBackupManager(discardLogger(), []Rule{rule}, nil, &backupRepositoryMock{}, nil)
+
+	// queue of the rule is taken by cron backup
+	m.active[rule.Name] <- Backup{Id: 41, Rule: rule.Name}
+
+	retryAt := time.Now().Add(50 * time.Millisecond)
+	retry := Backup{Id: 42, Rule: rule.Name, ExecStatus: ExecStatusNew, RetryAt: &retryAt}
+
+	go m.enqueueAt(context.Background(), retry)
+
+	assert.Equal(t, int64(41), (<-m.active[rule.Name]).Id)
+
+	select {
+	case <-m.active[rule.Name]:
+		t.Fatal("retry has been enqueued before its time")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	// retry waits for the queue rather than being dropped
+	m.active[rule.Name] <- Backup{Id: 43, Rule: rule.Name}
+	time.Sleep(100 * time.Millisecond)
+
+	assert.Equal(t, int64(43), (<-m.active[rule.Name]).Id)
+
+	select {
+	case b := <-m.active[rule.Name]:
+		assert.Equal(t, retry, b)
+	case <-time.After(time.Second):
+		t.Fatal("retry has not been enqueued")
+	}
+}
@@ -0,0 +1,79 @@
+package domain
+
+import (
+	"fmt"
+)
+
+// Rotation rules or retention of copies stored in particular storage
+type StorageRotation struct {
+	Storage       string         `mapstructure:"storage"`
+	RotationRules []RotationRule `mapstructure:"rotation_rules"`
+	Retention     *GFSRetention  `mapstructure:"retention"`
+}
+
+// StorageRotationRules returns rotation rules of copies stored in given storage
+func (r Rule) StorageRotationRules(storage string) []RotationRule {
+	for _, sr := range r.StorageRotation {
+		if sr.Storage == storage {
+			return sr.RotationRules
+		}
+	}
+
+	return r.RotationRules
+}
+
+// ValidateRotation checks that either rotation rules or retention is used
+// and they're overridden only for storages of the rule and only once
+func (r Rule) ValidateRotation() error {
+	if r.Retention != nil {
+		if len(r.RotationRules) > 0 {
+			return fmt.Errorf("rotation_rules and retention are mutually exclusive")
+		}
+
+		if err := r.Retention.Validate(); err != nil {
+			return err
+		}
+	}
+
+	storages := make(map[string]bool)
+	for _, storage := range r.StorageNames() {
+		storages[storage] = true
+	}
+
+	seen := make(map[string]bool, len(r.StorageRotation))
+
+	for _, sr := range r.StorageRotation {
+		if !storages[sr.Storage] {
+			return fmt.Errorf("storage_rotation refers to unknown storage '%s'", sr.Storage)
+		}
+
+		if seen[sr.Storage] {
+			return fmt.Errorf("storage_rotation of '%s' is specified more than once", sr.Storage)
+		}
+		seen[sr.Storage] = true
+
+		if sr.Retention != nil {
+			if len(sr.RotationRules) > 0 {
+				return fmt.Errorf("rotation_rules and retention of storage '%s' are mutually exclusive", sr.Storage)
+			}
+
+			if err := sr.Retention.Validate(); err != nil {
+				return fmt.Errorf("storage '%s': %s", sr.Storage, err)
+			}
+
+			continue
+		}
+
+		if len(sr.RotationRules) == 0 {
+			return fmt.Errorf("rotation_rules of storage '%s' are not specified", sr.Storage)
+		}
+
+		for _, rr := range sr.RotationRules {
+			if rr.PreserveAtMost <= 0 {
+				return fmt.Errorf("preserve_at_most of storage '%s' must be positive", sr.Storage)
+			}
+		}
+	}
+
+	return nil
+}
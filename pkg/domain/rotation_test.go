@@ -0,0 +1,91 @@
+package domain
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRule_StorageRotationRules(t *testing.T) {
+	hourly := []RotationRule{{Period: time.Hour, PreserveAtMost: 24}}
+	daily := []RotationRule{{Period: 24 * time.Hour, PreserveAtMost: 7}}
+
+	rule := Rule{
+		Storages:        []string{"local", "s3"},
+		RotationRules:   hourly,
+		StorageRotation: []StorageRotation{{Storage: "s3", RotationRules: daily}},
+	}
+
+	assert.Equal(t, hourly, rule.StorageRotationRules("local"))
+	assert.Equal(t, daily, rule.StorageRotationRules("s3"))
+}
+
+func TestRule_ValidateRotation(t *testing.T) {
+	daily := []RotationRule{{Period: 24 * time.Hour, PreserveAtMost: 7}}
+
+	assert.Nil(t, Rule{StorageName: "local"}.ValidateRotation())
+	assert.Nil(t, Rule{StorageName: "local", StorageRotation: []StorageRotation{{Storage: "local", RotationRules: daily}}}.ValidateRotation())
+	assert.Nil(t, Rule{Storages: []string{"local", "s3"}, StorageRotation: []StorageRotation{{Storage: "s3", RotationRules: daily}}}.ValidateRotation())
+
+	cases := []Rule{
+		{StorageName: "local", StorageRotation: []StorageRotation{{Storage: "s3", RotationRules: daily}}},
+		{StorageName: "local", StorageRotation: []StorageRotation{{Storage: "local"}}},
+		{StorageName: "local", StorageRotation: []StorageRotation{{Storage: "local", RotationRules: []RotationRule{{Period: time.Hour}}}}},
+		{StorageName: "local", StorageRotation: []StorageRotation{
+			{Storage: "local", RotationRules: daily},
+			{Storage: "local", RotationRules: daily},
+		}},
+	}
+
+	for _, rule := range cases {
+		assert.NotNil(t, rule.ValidateRotation(), "%v", rule.StorageRotation)
+	}
+}
+
+func TestManager_sweepOldBackups_PerStorage(t *testing.T) {
+	repo := &backupRepositoryMock{}
+	service := &backupServiceMock{}
+
+	rule := Rule{
+		Name:     "some-rule",
+		Storages: []string{"local", "s3"},
+		RotationRules: []RotationRule{
+			{Period: time.Hour, PreserveAtMost: 3},
+		},
+		StorageRotation: []StorageRotation{{Storage: "s3", RotationRules: []RotationRule{
+			{Period: time.Hour, PreserveAtMost: 1},
+			{Period: 24 * time.Hour, PreserveAtMost: 1},
+		}}},
+	}
+
+	now := time.Now()
+
+	copies := func(storage string) []Backup {
+		var backups []Backup
+		for i := 0; i < 3; i++ {
+			backups = append(backups, Backup{Id: int64(i + 1), Rule: rule.Name, StorageName: storage, CreatedAt: now.Add(time.Duration(i-3) * time.Hour)})
+		}
+		return backups
+	}
+
+	// three copies fit into the single generation of local storage
+	repo.On("FindAllSuccessfulNotDeletedCopies", mock.Anything, rule, "local").Return(copies("local"), nil)
+
+	// only the newest one is kept in generation 0 of s3, the oldest one is pushed
+	// to generation 1 and the other one is discarded as it's too close to it
+	repo.On("FindAllSuccessfulNotDeletedCopies", mock.Anything, rule, "s3").Return(copies("s3"), nil)
+	repo.On("UpdateCopyGeneration", mock.Anything, int64(1), "s3", 1).Return(nil).Once()
+	service.On("DeleteCopy", mock.Anything, mock.MatchedBy(func(b Backup) bool {
+		return b.Id == 2 && b.StorageName == "s3"
+	})).Return(nil).Once()
+
+	m := NewBackupManager(discardLogger(), []Rule{rule}, service, repo, nil)
+
+	m.sweepOldBackups(context.Background(), rule)
+
+	repo.AssertExpectations(t)
+	service.AssertExpectations(t)
+}
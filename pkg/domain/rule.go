@@ -1,6 +1,14 @@
 package domain
 
-import "time"
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/yurykabanov/backuper/pkg/archive"
+	"github.com/yurykabanov/backuper/pkg/encryption"
+)
 
 type Rule struct {
 	Name            string         `mapstructure:"name"`
@@ -11,9 +19,147 @@ type Rule struct {
 	CronSpec        string         `mapstructure:"cron_spec"`
 	RotationRules   []RotationRule `mapstructure:"rotation_rules"`
 	StorageName     string         `mapstructure:"storage_name"`
+
+	// Storages the archive is replicated to instead of single StorageName,
+	// backup is successful when Quorum of copies are stored (all by default)
+	Storages []string `mapstructure:"storages"`
+	Quorum   int      `mapstructure:"quorum"`
+
+	// Calendar-aligned retention used instead of RotationRules
+	Retention *GFSRetention `mapstructure:"retention"`
+
+	// Rotation rules or retention of particular storages overriding ones of the rule
+	StorageRotation []StorageRotation `mapstructure:"storage_rotation"`
+
+	// Retries of failed backups, failed backups aren't retried by default
+	Retry RetryPolicy `mapstructure:"retry"`
+
+	// When images are pulled: always (default), if-not-present or never
+	ImagePullPolicy string `mapstructure:"image_pull_policy"`
+
+	// Run command in already running container (name, id or "label=key[=value]")
+	// instead of starting new one, its stdout is stored as OutputFile
+	ExecIn string `mapstructure:"exec_in"`
+
+	// Where results of the command are taken from: directory (default) or stdout,
+	// the latter is stored as OutputFile ("dump" by default)
+	Output     string `mapstructure:"output"`
+	OutputFile string `mapstructure:"output_file"`
+
+	// Environment of containers: KEY=value entries (${VAR} is taken from environment of backuper),
+	// files with KEY=value lines and variables which values are read from files
+	Env      []string `mapstructure:"env"`
+	EnvFiles []string `mapstructure:"env_files"`
+	Secrets  []Secret `mapstructure:"secrets"`
+
+	// Additional mounts and volumes of other containers ("container[:ro|rw]")
+	Mounts      []Mount  `mapstructure:"mounts"`
+	VolumesFrom []string `mapstructure:"volumes_from"`
+
+	// Network of containers ("host" by default): host, bridge, none, container:<name|id>
+	// or name of user-defined network; aliases are supported only by user-defined networks
+	Network        string   `mapstructure:"network"`
+	NetworkAliases []string `mapstructure:"network_aliases"`
+	// Links to other containers in form of "name:alias"
+	Links []string `mapstructure:"links"`
+	// Additional /etc/hosts entries in form of "host:ip"
+	ExtraHosts []string `mapstructure:"extra_hosts"`
+
+	// Limits of dumper container: number of CPUs (e.g. 0.5) and memory (e.g. "512m")
+	CPUs   float64 `mapstructure:"cpus"`
+	Memory string  `mapstructure:"memory"`
+
+	// User (name or uid[:gid]) and working directory of the command
+	User       string `mapstructure:"user"`
+	WorkingDir string `mapstructure:"working_dir"`
+
+	// Security options of dumper container: read-only root filesystem,
+	// dropped capabilities (e.g. "ALL") and options like "no-new-privileges"
+	ReadOnly    bool     `mapstructure:"read_only"`
+	CapDrop     []string `mapstructure:"cap_drop"`
+	SecurityOpt []string `mapstructure:"security_opt"`
+
+	// Archive format: zip (default), tar, tar.gz or tar.zst
+	Archive string `mapstructure:"archive"`
+
+	// Stream archive directly to storage instead of creating it in temp directory first
+	Streaming bool `mapstructure:"streaming"`
+
+	// Image and command used to restore backups (image of the rule is used if not specified)
+	RestoreImage   string        `mapstructure:"restore_image"`
+	RestoreCommand []string      `mapstructure:"restore_command"`
+	RestoreTimeout time.Duration `mapstructure:"restore_timeout"`
+
+	// Encryption of archives (encryption of the storage is used if not specified)
+	Encryption *encryption.Config `mapstructure:"encryption"`
 }
 
 type RotationRule struct {
 	Period         time.Duration `mapstructure:"period"`
 	PreserveAtMost int           `mapstructure:"preserve_at_most"`
 }
+
+// Validate checks options of the rule which can't be checked by type system
+func (r Rule) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("name is not specified")
+	}
+
+	_, err := archive.Get(r.Archive)
+	if err != nil {
+		return err
+	}
+
+	err = r.ValidateStorages()
+	if err != nil {
+		return err
+	}
+
+	err = r.ValidateRotation()
+	if err != nil {
+		return err
+	}
+
+	err = r.Retry.Validate()
+	if err != nil {
+		return err
+	}
+
+	switch r.ImagePullPolicy {
+	case "", ImagePullAlways, ImagePullIfNotPresent, ImagePullNever:
+	default:
+		return fmt.Errorf("unknown image_pull_policy '%s'", r.ImagePullPolicy)
+	}
+
+	switch r.Output {
+	case "", OutputDirectory, OutputStdout:
+	default:
+		return fmt.Errorf("unknown output '%s'", r.Output)
+	}
+
+	if r.OutputFile != "" && (path.Base(r.OutputFile) != r.OutputFile || strings.HasPrefix(r.OutputFile, ".")) {
+		return fmt.Errorf("output_file must be a plain file name")
+	}
+
+	err = r.ValidateEnv()
+	if err != nil {
+		return err
+	}
+
+	err = r.ValidateMounts()
+	if err != nil {
+		return err
+	}
+
+	err = r.ValidateNetwork()
+	if err != nil {
+		return err
+	}
+
+	err = r.ValidateResources()
+	if err != nil {
+		return err
+	}
+
+	return r.ValidateExec()
+}
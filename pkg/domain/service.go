@@ -4,9 +4,9 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
 	"path"
+	"sync"
 	"time"
 
 	"github.com/docker/distribution/reference"
@@ -18,21 +18,61 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"github.com/yurykabanov/backuper/pkg/appcontext"
-	"github.com/yurykabanov/backuper/pkg/util"
+	"github.com/yurykabanov/backuper/pkg/archive"
+	"github.com/yurykabanov/backuper/pkg/encryption"
 )
 
 const maxErrorsWhileFinishing = 100
 
+// Label of containers started by backuper itself
+const ManagedLabel = "backuper.managed"
+
+var managedLabels = map[string]string{ManagedLabel: "true"}
+
+var (
+	ErrRuleNotFound         = errors.New("rule not found")
+	ErrBackupNotFound       = errors.New("backup not found")
+	ErrBackupNotRestorable  = errors.New("backup is not successful or has been deleted")
+	ErrRestoreNotConfigured = errors.New("restore command is not configured for rule")
+	ErrRestoreRuleNotFound  = errors.New("rule of backup is not in configuration, backups of discovered rules can't be restored")
+	ErrRuleBusy             = errors.New("rule already has pending backup")
+	ErrRuleConflict         = errors.New("rule is defined in configuration and can't be changed")
+	ErrBackupEncrypted      = errors.New("backup is encrypted and should be decrypted manually")
+)
+
 type BackupRepository interface {
 	Create(context.Context, Backup) (Backup, error)
 	Update(context.Context, Backup) error
+	FindById(context.Context, int64) (Backup, error)
+	SaveLogs(context.Context, int64, string) error
 	FindAllUnfinished(context.Context) ([]Backup, error)
-	FindAllSuccessfulNotDeleted(context.Context, Rule) ([]Backup, error)
+	// Successful backups of the rule as they're stored in given storage (with
+	// generation, file and deletion time of the copy) ordered by creation time
+	FindAllSuccessfulNotDeletedCopies(context.Context, Rule, string) ([]Backup, error)
+	UpdateCopyGeneration(context.Context, int64, string, int) error
+	// Creates or replaces copy of backup in storage
+	SaveCopy(context.Context, BackupCopy) error
+	FindCopies(context.Context, int64) ([]BackupCopy, error)
 }
 
 type TransferManager interface {
 	Transfer(Backup) (string, error)
+	// Transfers archive with given extension read from stream of unknown size
+	TransferStream(Backup, string, io.Reader) (string, error)
 	Remove(Backup) error
+	Open(Backup) (io.ReadCloser, error)
+}
+
+// Encrypts archives before transferring them to storage
+type Encrypter interface {
+	Encrypt(io.Writer) (io.WriteCloser, error)
+	Extension() string
+}
+
+// Provides credentials of registries images are pulled from
+type RegistryAuth interface {
+	// Credentials encoded for docker API, empty string if registry doesn't need them
+	EncodedAuth(registry string) (string, error)
 }
 
 type MountManager interface {
@@ -66,6 +106,50 @@ type dockerClient interface {
 		options types.ContainerRemoveOptions,
 	) error
 
+	ContainerAttach(
+		ctx context.Context,
+		containerID string,
+		options types.ContainerAttachOptions,
+	) (types.HijackedResponse, error)
+
+	ContainerLogs(
+		ctx context.Context,
+		containerID string,
+		options types.ContainerLogsOptions,
+	) (io.ReadCloser, error)
+
+	ContainerList(
+		ctx context.Context,
+		options types.ContainerListOptions,
+	) ([]types.Container, error)
+
+	ContainerInspect(
+		ctx context.Context,
+		containerID string,
+	) (types.ContainerJSON, error)
+
+	ContainerExecCreate(
+		ctx context.Context,
+		containerID string,
+		config types.ExecConfig,
+	) (types.IDResponse, error)
+
+	ContainerExecAttach(
+		ctx context.Context,
+		execID string,
+		config types.ExecConfig,
+	) (types.HijackedResponse, error)
+
+	ContainerExecInspect(
+		ctx context.Context,
+		execID string,
+	) (types.ContainerExecInspect, error)
+
+	ImageInspectWithRaw(
+		ctx context.Context,
+		imageID string,
+	) (types.ImageInspect, []byte, error)
+
 	ImagePull(
 		ctx context.Context,
 		ref string,
@@ -80,6 +164,15 @@ type BackupService struct {
 	docker          dockerClient
 	mountManager    MountManager
 	transferManager TransferManager
+	registryAuth    RegistryAuth
+
+	// Encrypters by rule name, archives of rules without encrypter are stored as is
+	encrypters   map[string]Encrypter
+	encryptersMu sync.RWMutex
+
+	// Captured stdout of running containers by container id
+	outputsMu sync.Mutex
+	outputs   map[string]*outputCapture
 }
 
 func NewBackupService(
@@ -88,20 +181,68 @@ func NewBackupService(
 	docker dockerClient,
 	mountManager MountManager,
 	transferManager TransferManager,
+	encrypters map[string]Encrypter,
+	registryAuth RegistryAuth,
 ) *BackupService {
-	return &BackupService{
+	svc := &BackupService{
 		logger:          logger,
 		repo:            repo,
 		docker:          docker,
 		mountManager:    mountManager,
 		transferManager: transferManager,
+		registryAuth:    registryAuth,
+		encrypters:      make(map[string]Encrypter, len(encrypters)),
+		outputs:         make(map[string]*outputCapture),
+	}
+
+	// Copied since encrypters could be changed at runtime
+	for rule, encrypter := range encrypters {
+		svc.encrypters[rule] = encrypter
 	}
+
+	return svc
+}
+
+// SetEncrypter sets encrypter of rule registered at runtime, nil disables encryption.
+// Previous encrypter of the rule is returned
+func (s *BackupService) SetEncrypter(rule string, encrypter Encrypter) Encrypter {
+	s.encryptersMu.Lock()
+	defer s.encryptersMu.Unlock()
+
+	prev := s.encrypters[rule]
+
+	if encrypter == nil {
+		delete(s.encrypters, rule)
+		return prev
+	}
+
+	s.encrypters[rule] = encrypter
+
+	return prev
 }
 
-func (s *BackupService) StartBackup(ctx context.Context, rule Rule) (Backup, error) {
+// UnsetEncrypter removes encrypter of rule unless it has been replaced by SetEncrypter since
+func (s *BackupService) UnsetEncrypter(rule string, encrypter Encrypter) {
+	s.encryptersMu.Lock()
+	defer s.encryptersMu.Unlock()
+
+	if encrypter != nil && s.encrypters[rule] == encrypter {
+		delete(s.encrypters, rule)
+	}
+}
+
+func (s *BackupService) encrypter(rule string) (Encrypter, bool) {
+	s.encryptersMu.RLock()
+	defer s.encryptersMu.RUnlock()
+
+	encrypter, ok := s.encrypters[rule]
+	return encrypter, ok
+}
+
+// StartBackup starts dumper container for backup previously dispatched (and stored with ExecStatusNew)
+func (s *BackupService) StartBackup(ctx context.Context, rule Rule, backup Backup) (Backup, error) {
 	logger := appcontext.LoggerFromContext(s.logger, ctx)
 
-	var backup Backup
 	var err error
 
 	defer func() {
@@ -116,11 +257,14 @@ func (s *BackupService) StartBackup(ctx context.Context, rule Rule) (Backup, err
 		}
 	}()
 
-	backup = Backup{
-		Rule:        rule.Name,
-		ExecStatus:  ExecStatusCreated,
-		CreatedAt:   time.Now(),
-		StorageName: rule.StorageName,
+	// Backup may have been waiting in queue for a while, so timeout starts from now
+	backup.ExecStatus = ExecStatusCreated
+	backup.CreatedAt = time.Now()
+	backup.StorageName = rule.StorageNames()[0]
+
+	if rule.ExecIn != "" {
+		backup, err = s.startExec(ctx, rule, backup)
+		return backup, err
 	}
 
 	ref, err := reference.ParseNormalizedNamed(rule.Image)
@@ -128,7 +272,12 @@ func (s *BackupService) StartBackup(ctx context.Context, rule Rule) (Backup, err
 		return backup, err
 	}
 
-	err = s.pullImage(ctx, ref)
+	env, err := rule.containerEnv("BACKUP_TARGET_DIR=/__backup__")
+	if err != nil {
+		return backup, err
+	}
+
+	err = s.pullImage(ctx, ref, rule.ImagePullPolicy)
 	if err != nil {
 		return backup, err
 	}
@@ -140,35 +289,62 @@ func (s *BackupService) StartBackup(ctx context.Context, rule Rule) (Backup, err
 
 	backup.TempDirectory = dir
 
-	backup, err = s.repo.Create(ctx, backup)
+	err = s.repo.Update(ctx, backup)
+	if err != nil {
+		return backup, err
+	}
+
+	hostConfig := &container.HostConfig{
+		Mounts: []mount.Mount{
+			{Type: mount.TypeBind, Source: dir, Target: "/__backup__"},
+		},
+	}
+
+	config := &container.Config{
+		Image:  ref.String(),
+		Cmd:    rule.Command,
+		Env:    env,
+		Labels: managedLabels,
+	}
+
+	rule.applyMounts(hostConfig, false)
+	networkingConfig := rule.applyNetwork(hostConfig)
+
+	err = rule.applyResources(config, hostConfig)
 	if err != nil {
 		return backup, err
 	}
 
+	if rule.Output == OutputStdout {
+		// Dump must not be copied into container logs by docker
+		hostConfig.LogConfig = container.LogConfig{Type: "none"}
+	}
+
 	c, err := s.docker.ContainerCreate(
 		ctx,
-		&container.Config{
-			Image: ref.String(),
-			Cmd:   rule.Command,
-			Env: []string{
-				"BACKUP_TARGET_DIR=/__backup__",
-			},
-		}, // container config
-		&container.HostConfig{
-			NetworkMode: "host",
-			Mounts: []mount.Mount{
-				{Type: mount.TypeBind, Source: dir, Target: "/__backup__"},
-			},
-		}, // host config
-		&network.NetworkingConfig{}, // networking config
+		config,           // container config
+		hostConfig,       // host config
+		networkingConfig, // networking config
 		s.containerName(backup),
 	)
 	if err != nil {
 		return backup, err
 	}
 
+	if rule.Output == OutputStdout {
+		// Attaching before start guarantees that no output is lost
+		err = s.attachOutput(ctx, rule, c.ID, dir)
+		if err != nil {
+			return backup, err
+		}
+	}
+
 	err = s.docker.ContainerStart(ctx, c.ID, types.ContainerStartOptions{})
 	if err != nil {
+		if capture := s.detachOutput(c.ID); capture != nil {
+			capture.close()
+		}
+
 		return backup, err
 	}
 
@@ -183,15 +359,27 @@ func (s *BackupService) StartBackup(ctx context.Context, rule Rule) (Backup, err
 	return backup, nil
 }
 
-func (s *BackupService) FinishBackup(ctx context.Context, backup Backup) (Backup, error) {
+func (s *BackupService) FinishBackup(ctx context.Context, rule Rule, backup Backup) (Backup, error) {
+	if backup.ExecId != "" {
+		return s.finishExec(ctx, rule, backup)
+	}
+
 	logger := appcontext.LoggerFromContext(s.logger, ctx)
 
 	var status int64
 	var err error
 
+	capture := s.detachOutput(backup.ContainerId)
+
 	defer func() {
 		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 
+		if capture != nil {
+			capture.close()
+		}
+
+		s.saveLogs(backup, capture)
+
 		if err := s.docker.ContainerRemove(ctx, backup.ContainerId, types.ContainerRemoveOptions{Force: true}); err != nil {
 			logger.WithError(err).Error("BackupService::FinishBackup is unable to remove container")
 		}
@@ -220,34 +408,88 @@ func (s *BackupService) FinishBackup(ctx context.Context, backup Backup) (Backup
 
 	backup.StatusCode = status
 
+	if capture != nil {
+		// Container has exited, but the rest of its output may still be in transit
+		err = capture.wait(ctx)
+		if err != nil {
+			_, _ = s.markWithStatusAndDeallocate(backup, ExecStatusFailure)
+
+			return backup, fmt.Errorf("unable to capture output: %s", err)
+		}
+	} else if rule.Output == OutputStdout {
+		_, _ = s.markWithStatusAndDeallocate(backup, ExecStatusFailure)
+
+		return backup, errors.New("output of container has not been captured")
+	}
+
 	if status != 0 {
 		_, _ = s.markWithStatusAndDeallocate(backup, ExecStatusFailure)
 
 		return backup, errors.New("status code is not zero")
 	}
 
-	tempBackupFile := path.Join(backup.TempDirectory, "__backup__.zip")
-	err = util.ZipDirectory(tempBackupFile, backup.TempDirectory)
+	return s.storeResults(ctx, rule, backup)
+}
+
+// storeResults packs results of successfully finished command into archive
+// and transfers it to storage
+func (s *BackupService) storeResults(ctx context.Context, rule Rule, backup Backup) (Backup, error) {
+	logger := appcontext.LoggerFromContext(s.logger, ctx)
+
+	format, err := archive.Get(rule.Archive)
 	if err != nil {
 		_, _ = s.markWithStatusAndDeallocate(backup, ExecStatusFailure)
 
-		return backup, fmt.Errorf("unable to zip temp data: %s", err)
+		return backup, err
+	}
+
+	if rule.Streaming {
+		backup, err = s.streamArchive(format, backup)
+		if err != nil {
+			_, _ = s.markWithStatusAndDeallocate(backup, ExecStatusFailure)
+
+			return backup, err
+		}
+
+		c := BackupCopy{BackupId: backup.Id, StorageName: backup.StorageName, ExecStatus: ExecStatusSuccess, BackupFile: backup.BackupFile, CreatedAt: time.Now()}
+		if err := s.repo.SaveCopy(ctx, c); err != nil {
+			logger.WithError(err).Error("Unable to save copy of backup")
+		}
+
+		return s.markWithStatusAndDeallocate(backup, ExecStatusSuccess)
+	}
+
+	tempBackupFile := path.Join(backup.TempDirectory, "__backup__"+format.Extension())
+	err = format.Create(tempBackupFile, backup.TempDirectory)
+	if err != nil {
+		_, _ = s.markWithStatusAndDeallocate(backup, ExecStatusFailure)
+
+		return backup, fmt.Errorf("unable to archive temp data: %s", err)
 	}
 	backup.TempBackupFile = tempBackupFile
 
-	if tmpFileStat, err := os.Stat(tempBackupFile); err == nil {
+	if encrypter, ok := s.encrypter(backup.Rule); ok {
+		encryptedBackupFile := tempBackupFile + encrypter.Extension()
+
+		err = s.encryptArchive(encrypter, tempBackupFile, encryptedBackupFile)
+		if err != nil {
+			_, _ = s.markWithStatusAndDeallocate(backup, ExecStatusFailure)
+
+			return backup, fmt.Errorf("unable to encrypt archive: %s", err)
+		}
+		backup.TempBackupFile = encryptedBackupFile
+	}
+
+	if tmpFileStat, err := os.Stat(backup.TempBackupFile); err == nil {
 		backup.BackupSize = tmpFileStat.Size()
 	} else {
 		logger.WithError(err).Warn("Unable to calculate backup size in spite of it has finished successfully")
 	}
 
-	storageBackupFile, err := s.transferManager.Transfer(backup)
+	backup, err = s.transferCopies(ctx, rule, backup)
 	if err != nil {
-		_, _ = s.markWithStatusAndDeallocate(backup, ExecStatusFailure)
-
-		return backup, err
+		return s.failTransfer(ctx, rule, backup, err)
 	}
-	backup.BackupFile = storageBackupFile
 
 	return s.markWithStatusAndDeallocate(backup, ExecStatusSuccess)
 }
@@ -255,9 +497,19 @@ func (s *BackupService) FinishBackup(ctx context.Context, backup Backup) (Backup
 func (s *BackupService) AbortBackup(ctx context.Context, backup Backup) error {
 	logger := appcontext.LoggerFromContext(s.logger, ctx)
 
+	// Container of exec isn't ours, output of exec is lost after restart anyway,
+	// container of dumped backup has been removed already and pending retry
+	// doesn't have one yet
+	if backup.ExecId != "" || backup.ExecStatus == ExecStatusDumped || backup.ExecStatus == ExecStatusNew {
+		_, err := s.markWithStatusAndDeallocate(backup, ExecStatusFailure)
+		return err
+	}
+
 	defer func() {
 		ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 
+		s.saveLogs(backup, nil)
+
 		if err := s.docker.ContainerRemove(ctx, backup.ContainerId, types.ContainerRemoveOptions{Force: true}); err != nil {
 			logger.WithError(err).Error("BackupService::FinishBackup is unable to remove container")
 		}
@@ -270,28 +522,271 @@ func (s *BackupService) AbortBackup(ctx context.Context, backup Backup) error {
 	return err
 }
 
-func (s *BackupService) pullImage(ctx context.Context, ref reference.Named) error {
-	img, err := s.docker.ImagePull(
+// RestoreBackup fetches archive of given backup from its storage, unpacks it
+// into temp directory and runs restore container of the rule with unpacked
+// data mounted at $BACKUP_SOURCE_DIR
+func (s *BackupService) RestoreBackup(ctx context.Context, rule Rule, backup Backup) error {
+	logger := appcontext.LoggerFromContext(s.logger, ctx)
+
+	if len(rule.RestoreCommand) == 0 {
+		return ErrRestoreNotConfigured
+	}
+
+	if backup.ExecStatus != ExecStatusSuccess || backup.DeletedAt != nil {
+		return ErrBackupNotRestorable
+	}
+
+	if encryption.IsEncrypted(backup.BackupFile) {
+		return ErrBackupEncrypted
+	}
+
+	image := rule.RestoreImage
+	if image == "" {
+		image = rule.Image
+	}
+
+	ref, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return err
+	}
+
+	err = s.pullImage(ctx, ref, rule.ImagePullPolicy)
+	if err != nil {
+		return err
+	}
+
+	dir, err := s.mountManager.AllocateTemp()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := s.mountManager.DeallocateTemp(dir); err != nil {
+			logger.WithError(err).Error("BackupService::RestoreBackup is unable to deallocate temp directory")
+		}
+	}()
+
+	format, err := archive.ForFile(backup.BackupFile)
+	if err != nil {
+		return err
+	}
+
+	logger.Debug("Fetching backup archive")
+	archiveFile := path.Join(dir, "__restore__"+format.Extension())
+	err = s.fetchArchive(ctx, backup, archiveFile)
+	if err != nil {
+		return errors.Wrap(err, "unable to fetch backup archive")
+	}
+
+	err = format.Extract(archiveFile, dir)
+	if err != nil {
+		return errors.Wrap(err, "unable to extract backup archive")
+	}
+
+	err = os.Remove(archiveFile)
+	if err != nil {
+		return err
+	}
+
+	env, err := rule.containerEnv("BACKUP_SOURCE_DIR=/__restore__")
+	if err != nil {
+		return err
+	}
+
+	// Restore container shares mounts and network of the rule to reach the same data and services
+	hostConfig := &container.HostConfig{
+		Mounts: []mount.Mount{
+			{Type: mount.TypeBind, Source: dir, Target: "/__restore__"},
+		},
+	}
+
+	rule.applyMounts(hostConfig, true)
+	networkingConfig := rule.applyNetwork(hostConfig)
+
+	c, err := s.docker.ContainerCreate(
 		ctx,
-		ref.String(),
-		types.ImagePullOptions{},
+		&container.Config{
+			Image:  ref.String(),
+			Cmd:    rule.RestoreCommand,
+			Env:    env,
+			Labels: managedLabels,
+		}, // container config
+		hostConfig,       // host config
+		networkingConfig, // networking config
+		s.restoreContainerName(backup),
 	)
 	if err != nil {
 		return err
 	}
 
-	_, err = io.Copy(ioutil.Discard, img)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+
+		if err := s.docker.ContainerRemove(ctx, c.ID, types.ContainerRemoveOptions{Force: true}); err != nil {
+			logger.WithError(err).Error("BackupService::RestoreBackup is unable to remove container")
+		}
+
+		cancel()
+	}()
+
+	logger = logger.WithField("container_id", c.ID)
+
+	err = s.docker.ContainerStart(ctx, c.ID, types.ContainerStartOptions{})
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Awaiting restore to finish")
+	status, err := s.docker.ContainerWait(ctx, c.ID)
 	if err != nil {
 		return err
 	}
 
+	if status != 0 {
+		return fmt.Errorf("restore container exited with status code %d", status)
+	}
+
 	return nil
 }
 
+// Encrypts archive and removes unencrypted one
+func (s *BackupService) encryptArchive(encrypter Encrypter, src, dst string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if e := out.Close(); e != nil && err == nil {
+			err = e
+		}
+	}()
+
+	w, err := encrypter.Encrypt(out)
+	if err != nil {
+		return
+	}
+
+	_, err = io.Copy(w, in)
+	if err != nil {
+		return
+	}
+
+	err = w.Close()
+	if err != nil {
+		return
+	}
+
+	return os.Remove(src)
+}
+
+// Archives (and encrypts) temp data on the fly while it is being transferred to storage,
+// so neither archive nor its encrypted copy is ever stored in temp directory
+func (s *BackupService) streamArchive(format archive.Format, backup Backup) (Backup, error) {
+	ext := format.Extension()
+
+	encrypter, encrypted := s.encrypter(backup.Rule)
+	if encrypted {
+		ext += encrypter.Extension()
+	}
+
+	pr, pw := io.Pipe()
+	done := make(chan error, 1)
+
+	go func() {
+		done <- s.writeArchive(format, encrypter, backup.TempDirectory, pw)
+	}()
+
+	counter := &countingReader{r: pr}
+
+	storageBackupFile, err := s.transferManager.TransferStream(backup, ext, counter)
+
+	// Unblock writer in case transfer has stopped reading before the end of stream
+	_ = pr.CloseWithError(io.ErrClosedPipe)
+	werr := <-done
+
+	// Writer fails with closed pipe whenever transfer fails, so the error of
+	// transfer is the actual cause unless archiving has failed on its own
+	if werr != nil && (err == nil || errors.Cause(werr) != io.ErrClosedPipe) {
+		return backup, fmt.Errorf("unable to archive temp data: %s", werr)
+	}
+	if err != nil {
+		return backup, err
+	}
+
+	backup.BackupFile = storageBackupFile
+	backup.BackupSize = counter.n
+
+	return backup, nil
+}
+
+func (s *BackupService) writeArchive(format archive.Format, encrypter Encrypter, dir string, pw *io.PipeWriter) (err error) {
+	defer func() {
+		_ = pw.CloseWithError(err)
+	}()
+
+	if encrypter == nil {
+		return format.Write(pw, dir, "")
+	}
+
+	w, err := encrypter.Encrypt(pw)
+	if err != nil {
+		return
+	}
+
+	err = format.Write(w, dir, "")
+	if err != nil {
+		return
+	}
+
+	return w.Close()
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+func (s *BackupService) fetchArchive(ctx context.Context, backup Backup, target string) (err error) {
+	in, err := s.openCopy(ctx, backup)
+	if err != nil {
+		return
+	}
+	defer in.Close()
+
+	out, err := os.Create(target)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if e := out.Close(); e != nil && err == nil {
+			err = e
+		}
+	}()
+
+	_, err = io.Copy(out, in)
+
+	return
+}
+
 func (s *BackupService) containerName(backup Backup) string {
 	return fmt.Sprintf("backup-%s-%d", backup.Rule, backup.Id)
 }
 
+func (s *BackupService) restoreContainerName(backup Backup) string {
+	return fmt.Sprintf("restore-%s-%d", backup.Rule, backup.Id)
+}
+
 func (s *BackupService) markWithStatusAndDeallocate(backup Backup, execStatus execStatus) (Backup, error) {
 	now := time.Now()
 
@@ -311,7 +806,7 @@ func (s *BackupService) markWithStatusAndDeallocate(backup Backup, execStatus ex
 }
 
 func (s *BackupService) DeleteBackup(ctx context.Context, backup Backup) error {
-	err := s.transferManager.Remove(backup)
+	err := s.removeCopies(ctx, backup)
 	if err != nil {
 		return err
 	}
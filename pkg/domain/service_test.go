@@ -1,20 +1,31 @@
 package domain
 
 import (
+	"archive/zip"
+	"bufio"
+	"bytes"
 	"context"
+	"errors"
 	"io"
 	"io/ioutil"
+	"net"
+	"os"
+	"path"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/docker/distribution/reference"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/mount"
 	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/pkg/stdcopy"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+
+	"github.com/yurykabanov/backuper/pkg/archive"
 )
 
 // region mapBackupRepository
@@ -32,16 +43,41 @@ func (m *backupRepositoryMock) Update(ctx context.Context, backup Backup) error
 	return args.Error(0)
 }
 
+func (m *backupRepositoryMock) FindById(ctx context.Context, id int64) (Backup, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(Backup), args.Error(1)
+}
+
+func (m *backupRepositoryMock) SaveLogs(ctx context.Context, id int64, logs string) error {
+	args := m.Called(ctx, id, logs)
+	return args.Error(0)
+}
+
 func (m *backupRepositoryMock) FindAllUnfinished(ctx context.Context) ([]Backup, error) {
 	args := m.Called(ctx)
 	return args.Get(0).([]Backup), args.Error(1)
 }
 
-func (m *backupRepositoryMock) FindAllSuccessfulNotDeleted(ctx context.Context, rule Rule) ([]Backup, error) {
-	args := m.Called(ctx, rule)
+func (m *backupRepositoryMock) FindAllSuccessfulNotDeletedCopies(ctx context.Context, rule Rule, storage string) ([]Backup, error) {
+	args := m.Called(ctx, rule, storage)
 	return args.Get(0).([]Backup), args.Error(1)
 }
 
+func (m *backupRepositoryMock) UpdateCopyGeneration(ctx context.Context, id int64, storage string, generation int) error {
+	args := m.Called(ctx, id, storage, generation)
+	return args.Error(0)
+}
+
+func (m *backupRepositoryMock) SaveCopy(ctx context.Context, c BackupCopy) error {
+	args := m.Called(ctx, c)
+	return args.Error(0)
+}
+
+func (m *backupRepositoryMock) FindCopies(ctx context.Context, id int64) ([]BackupCopy, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).([]BackupCopy), args.Error(1)
+}
+
 // endregion
 
 // region dockerClientMock
@@ -86,6 +122,79 @@ func (m *dockerClientMock) ContainerRemove(
 	return args.Error(0)
 }
 
+func (m *dockerClientMock) ContainerAttach(
+	ctx context.Context,
+	containerID string,
+	options types.ContainerAttachOptions,
+) (types.HijackedResponse, error) {
+	args := m.Called(ctx, containerID, options)
+	return args.Get(0).(types.HijackedResponse), args.Error(1)
+}
+
+func (m *dockerClientMock) ContainerLogs(
+	ctx context.Context,
+	containerID string,
+	options types.ContainerLogsOptions,
+) (io.ReadCloser, error) {
+	args := m.Called(ctx, containerID, options)
+
+	if r := args.Get(0); r != nil {
+		return r.(io.ReadCloser), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (m *dockerClientMock) ContainerList(
+	ctx context.Context,
+	options types.ContainerListOptions,
+) ([]types.Container, error) {
+	args := m.Called(ctx, options)
+	return args.Get(0).([]types.Container), args.Error(1)
+}
+
+func (m *dockerClientMock) ContainerInspect(
+	ctx context.Context,
+	containerID string,
+) (types.ContainerJSON, error) {
+	args := m.Called(ctx, containerID)
+	return args.Get(0).(types.ContainerJSON), args.Error(1)
+}
+
+func (m *dockerClientMock) ContainerExecCreate(
+	ctx context.Context,
+	containerID string,
+	config types.ExecConfig,
+) (types.IDResponse, error) {
+	args := m.Called(ctx, containerID, config)
+	return args.Get(0).(types.IDResponse), args.Error(1)
+}
+
+func (m *dockerClientMock) ContainerExecAttach(
+	ctx context.Context,
+	execID string,
+	config types.ExecConfig,
+) (types.HijackedResponse, error) {
+	args := m.Called(ctx, execID, config)
+	return args.Get(0).(types.HijackedResponse), args.Error(1)
+}
+
+func (m *dockerClientMock) ContainerExecInspect(
+	ctx context.Context,
+	execID string,
+) (types.ContainerExecInspect, error) {
+	args := m.Called(ctx, execID)
+	return args.Get(0).(types.ContainerExecInspect), args.Error(1)
+}
+
+func (m *dockerClientMock) ImageInspectWithRaw(
+	ctx context.Context,
+	imageID string,
+) (types.ImageInspect, []byte, error) {
+	args := m.Called(ctx, imageID)
+	return args.Get(0).(types.ImageInspect), nil, args.Error(1)
+}
+
 func (m *dockerClientMock) ImagePull(
 	ctx context.Context,
 	ref string,
@@ -107,12 +216,12 @@ type mountManagerMock struct {
 	mock.Mock
 }
 
-func (m *mountManagerMock) Allocate() (string, error) {
+func (m *mountManagerMock) AllocateTemp() (string, error) {
 	args := m.Called()
 	return args.String(0), args.Error(1)
 }
 
-func (m *mountManagerMock) Deallocate(path string) error {
+func (m *mountManagerMock) DeallocateTemp(path string) error {
 	args := m.Called(path)
 	return args.Error(0)
 }
@@ -129,6 +238,32 @@ func (m *transferManagerMock) Transfer(backup Backup) (string, error) {
 	return args.String(0), args.Error(1)
 }
 
+// Stream is read completely before matching arguments, so its content can be asserted
+func (m *transferManagerMock) TransferStream(backup Backup, ext string, r io.Reader) (string, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+
+	args := m.Called(backup, ext, data)
+	return args.String(0), args.Error(1)
+}
+
+func (m *transferManagerMock) Remove(backup Backup) error {
+	args := m.Called(backup)
+	return args.Error(0)
+}
+
+func (m *transferManagerMock) Open(backup Backup) (io.ReadCloser, error) {
+	args := m.Called(backup)
+
+	if r := args.Get(0); r != nil {
+		return r.(io.ReadCloser), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
 // endregion
 
 // region namedReference
@@ -161,9 +296,9 @@ func TestService_pullImage_Success(t *testing.T) {
 	dockerClient.On("ImagePull", mock.Anything, mock.Anything, mock.Anything).
 		Return(ioutil.NopCloser(strings.NewReader("some response")), nil)
 
-	svc := NewBackupService(discardLogger(), repo, dockerClient, nil, nil)
+	svc := NewBackupService(discardLogger(), repo, dockerClient, nil, nil, nil, nil)
 
-	err := svc.pullImage(context.Background(), &namedReference{})
+	err := svc.pullImage(context.Background(), &namedReference{}, ImagePullAlways)
 
 	assert.Nil(t, err)
 }
@@ -176,13 +311,48 @@ func TestService_pullImage_Failure(t *testing.T) {
 	dockerClient.On("ImagePull", mock.Anything, mock.Anything, mock.Anything).
 		Return(io.ReadCloser(nil), context.DeadlineExceeded)
 
-	svc := NewBackupService(discardLogger(), repo, dockerClient, nil, nil)
+	svc := NewBackupService(discardLogger(), repo, dockerClient, nil, nil, nil, nil)
 
-	err := svc.pullImage(context.Background(), &namedReference{})
+	err := svc.pullImage(context.Background(), &namedReference{}, ImagePullAlways)
 
 	assert.Equal(t, context.DeadlineExceeded, err)
 }
 
+type imageNotFoundError struct{}
+
+func (imageNotFoundError) Error() string  { return "no such image" }
+func (imageNotFoundError) NotFound() bool { return true }
+
+type registryAuthStub map[string]string
+
+func (a registryAuthStub) EncodedAuth(registry string) (string, error) {
+	return a[registry], nil
+}
+
+func TestService_pullImage_Policy(t *testing.T) {
+	present, _ := reference.ParseNormalizedNamed("mysql:5.7")
+	missing, _ := reference.ParseNormalizedNamed("registry.example.com/dumper:1.0")
+
+	dockerClient := &dockerClientMock{}
+
+	dockerClient.On("ImageInspectWithRaw", mock.Anything, present.String()).Return(types.ImageInspect{}, nil)
+	dockerClient.On("ImageInspectWithRaw", mock.Anything, missing.String()).Return(types.ImageInspect{}, imageNotFoundError{})
+	dockerClient.On("ImagePull", mock.Anything, missing.String(), types.ImagePullOptions{RegistryAuth: "some-auth"}).
+		Return(ioutil.NopCloser(strings.NewReader("some response")), nil).Once()
+
+	auth := registryAuthStub{"registry.example.com": "some-auth"}
+	svc := NewBackupService(discardLogger(), &backupRepositoryMock{}, dockerClient, nil, nil, nil, auth)
+
+	ctx := context.Background()
+
+	assert.Nil(t, svc.pullImage(ctx, present, ImagePullIfNotPresent))
+	assert.Nil(t, svc.pullImage(ctx, present, ImagePullNever))
+	assert.Nil(t, svc.pullImage(ctx, missing, ImagePullIfNotPresent))
+	assert.NotNil(t, svc.pullImage(ctx, missing, ImagePullNever))
+
+	dockerClient.AssertExpectations(t)
+}
+
 // endregion
 
 // region Test: StartBackup
@@ -195,17 +365,17 @@ func TestService_StartBackup(t *testing.T) {
 	createdAt, _ := time.Parse(time.RFC3339, "2019-01-01T02:03:04Z")
 
 	newBackup := Backup{
-		Rule:          "some-rule",
-		ExecStatus:    ExecStatusCreated,
-		TempDirectory: "/tmp/temp_dir/some_mount_directory",
-		//TargetDirectory: "/tmp/whatever/",
-		CreatedAt: createdAt,
+		Id:         42,
+		Rule:       "some-rule",
+		ExecStatus: ExecStatusNew,
+		CreatedAt:  createdAt,
 	}
 
 	rule := Rule{
 		Name:            "some-rule",
 		Image:           "whatever/image:1.2.3",
 		TargetDirectory: "/tmp/whatever/",
+		StorageName:     "some-storage",
 		Command:         []string{"echo", "123", ">", "$BACKUP_TARGET_DIRECTORY/backup.dat"},
 	}
 
@@ -220,7 +390,9 @@ func TestService_StartBackup(t *testing.T) {
 	mountManager.On("AllocateTemp").
 		Return(tempDirectory, nil)
 
-	repo.On("Create", ctx, mock.AnythingOfType("Backup")).Return(newBackup, nil)
+	repo.On("Update", ctx, mock.MatchedBy(func(b Backup) bool {
+		return b.Id == 42 && b.ExecStatus == ExecStatusCreated && b.TempDirectory == tempDirectory
+	})).Return(nil).Once()
 
 	dockerClient.On("ContainerCreate", ctx,
 		&container.Config{
@@ -229,6 +401,7 @@ func TestService_StartBackup(t *testing.T) {
 			Env: []string{
 				"BACKUP_TARGET_DIR=/__backup__",
 			},
+			Labels: map[string]string{"backuper.managed": "true"},
 		},
 		&container.HostConfig{
 			NetworkMode: "host",
@@ -241,37 +414,162 @@ func TestService_StartBackup(t *testing.T) {
 	dockerClient.On("ContainerStart", ctx, containerId, mock.Anything).
 		Return(nil)
 
-	backup := newBackup
-	backup.ExecStatus = ExecStatusStarted
-	backup.ContainerId = "some-id"
-
-	repo.On("Update", ctx, backup).Return(nil)
+	repo.On("Update", ctx, mock.MatchedBy(func(b Backup) bool {
+		return b.Id == 42 && b.ExecStatus == ExecStatusStarted && b.ContainerId == containerId
+	})).Return(nil).Once()
 
-	svc := NewBackupService(discardLogger(), repo, dockerClient, mountManager, transferManager)
+	svc := NewBackupService(discardLogger(), repo, dockerClient, mountManager, transferManager, nil, nil)
 
-	backup, err := svc.StartBackup(ctx, rule)
+	backup, err := svc.StartBackup(ctx, rule, newBackup)
 
 	assert.Nil(t, err)
+	repo.AssertExpectations(t)
+	assert.Equal(t, int64(42), backup.Id)
 	assert.Equal(t, rule.Name, backup.Rule)
+	assert.Equal(t, rule.StorageName, backup.StorageName)
 	assert.Equal(t, ExecStatusStarted, backup.ExecStatus)
-	assert.NotEqual(t, "", backup.ContainerId)
+	assert.Equal(t, containerId, backup.ContainerId)
 	assert.Equal(t, tempDirectory, backup.TempDirectory)
+	// timeout is counted from the actual start rather than from dispatching
+	assert.True(t, backup.CreatedAt.After(createdAt))
+}
+
+func TestService_Backup_OutputStdout(t *testing.T) {
+	repo := &backupRepositoryMock{}
+	dockerClient := &dockerClientMock{}
+	mountManager := &mountManagerMock{}
+	transferManager := &transferManagerMock{}
+
+	tempDirectory, err := ioutil.TempDir("", "backuper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDirectory)
+
+	rule := Rule{
+		Name:       "some-rule",
+		Image:      "postgres:11",
+		Command:    []string{"pg_dumpall"},
+		Output:     OutputStdout,
+		OutputFile: "all.sql",
+	}
+
+	containerId := "some-id"
+	ctx := context.Background()
+
+	// container writes multiplexed stdout and stderr into attached connection
+	client, server := net.Pipe()
+	go func() {
+		_, _ = stdcopy.NewStdWriter(server, stdcopy.Stdout).Write([]byte("some dump"))
+		_, _ = stdcopy.NewStdWriter(server, stdcopy.Stderr).Write([]byte("some warning"))
+		_ = server.Close()
+	}()
+
+	dockerClient.On("ImagePull", ctx, mock.Anything, mock.Anything).
+		Return(ioutil.NopCloser(strings.NewReader("some response")), nil)
+	mountManager.On("AllocateTemp").Return(tempDirectory, nil)
+	repo.On("Update", mock.Anything, mock.AnythingOfType("Backup")).Return(nil)
+
+	dockerClient.On("ContainerCreate", ctx, mock.Anything, mock.MatchedBy(func(c *container.HostConfig) bool {
+		return c.LogConfig.Type == "none"
+	}), mock.Anything, mock.Anything).Return(container.ContainerCreateCreatedBody{ID: containerId}, nil)
+
+	dockerClient.On("ContainerAttach", ctx, containerId, types.ContainerAttachOptions{Stream: true, Stdout: true, Stderr: true}).
+		Return(types.HijackedResponse{Conn: client, Reader: bufio.NewReader(client)}, nil)
+	dockerClient.On("ContainerStart", ctx, containerId, mock.Anything).Return(nil)
+	dockerClient.On("ContainerWait", ctx, containerId).Return(int64(0), nil)
+	dockerClient.On("ContainerRemove", mock.Anything, containerId, mock.Anything).Return(nil)
+
+	// only stderr is stored since stdout is the backup itself
+	repo.On("SaveLogs", mock.Anything, int64(42), "some warning").Return(nil).Once()
+
+	var dump []byte
+
+	transferManager.On("Transfer", mock.MatchedBy(func(b Backup) bool {
+		zr, err := zip.OpenReader(b.TempBackupFile)
+		if err != nil {
+			return false
+		}
+		defer zr.Close()
+
+		for _, f := range zr.File {
+			if f.Name == "all.sql" {
+				r, _ := f.Open()
+				dump, _ = ioutil.ReadAll(r)
+				r.Close()
+			}
+		}
+
+		return true
+	})).Return("/transfer/some_file.zip", nil)
+	repo.On("SaveCopy", mock.Anything, mock.AnythingOfType("BackupCopy")).Return(nil)
+
+	mountManager.On("DeallocateTemp", tempDirectory).Return(nil)
+
+	svc := NewBackupService(discardLogger(), repo, dockerClient, mountManager, transferManager, nil, nil)
+
+	backup, err := svc.StartBackup(ctx, rule, Backup{Id: 42, Rule: rule.Name})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	backup, err = svc.FinishBackup(ctx, rule, backup)
+
+	assert.Nil(t, err)
+	assert.Equal(t, ExecStatusSuccess, backup.ExecStatus)
+	assert.Equal(t, []byte("some dump"), dump)
+	repo.AssertExpectations(t)
+}
+
+func TestTailBuffer(t *testing.T) {
+	buf := &tailBuffer{max: 16, lines: 2}
+
+	_, _ = buf.Write([]byte("first\nsecond\n"))
+	_, _ = buf.Write([]byte("third\n"))
+	assert.Equal(t, "second\nthird\n", buf.String())
+
+	_, _ = buf.Write([]byte("fourth"))
+	assert.Equal(t, "third\nfourth", buf.String())
+
+	// size is bounded as well
+	_, _ = buf.Write([]byte("\n0123456789abcdef"))
+	assert.Equal(t, "0123456789abcdef", buf.String())
 }
 
 // endregion
 
 // region Test: FinishBackup
+
+// Builds multiplexed logs stream as returned by docker
+func containerLogs(stdout, stderr string) io.ReadCloser {
+	buf := &bytes.Buffer{}
+	_, _ = stdcopy.NewStdWriter(buf, stdcopy.Stdout).Write([]byte(stdout))
+	_, _ = stdcopy.NewStdWriter(buf, stdcopy.Stderr).Write([]byte(stderr))
+	return ioutil.NopCloser(buf)
+}
+
 func TestService_FinishBackup(t *testing.T) {
 	repo := &backupRepositoryMock{}
 	dockerClient := &dockerClientMock{}
 	mountManager := &mountManagerMock{}
 	transferManager := &transferManagerMock{}
 
+	tempDirectory, err := ioutil.TempDir("", "backuper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDirectory)
+
+	err = ioutil.WriteFile(path.Join(tempDirectory, "dump.sql"), []byte("some dump"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
 	backup := Backup{
 		Rule:          "some-rule",
 		Id:            123456,
 		ContainerId:   "some-container-id",
-		TempDirectory: "/tmp/temp_dir",
+		TempDirectory: tempDirectory,
 		ExecStatus:    ExecStatusStarted,
 	}
 
@@ -280,29 +578,353 @@ func TestService_FinishBackup(t *testing.T) {
 	dockerClient.On("ContainerWait", ctx, backup.ContainerId).
 		Return(int64(0), nil)
 
-	transferManager.On("Transfer", backup).
-		Return("/transfer/some_dir", nil)
+	transferManager.On("Transfer", mock.AnythingOfType("Backup")).
+		Return("/transfer/some_file.zip", nil)
+
+	repo.On("SaveCopy", ctx, mock.MatchedBy(func(c BackupCopy) bool {
+		return c.BackupId == backup.Id &&
+			c.ExecStatus == ExecStatusSuccess &&
+			c.BackupFile == "/transfer/some_file.zip"
+	})).Return(nil).Once()
 
 	mountManager.On("DeallocateTemp", backup.TempDirectory).
 		Return(nil)
 
-	repo.On("Update", ctx, Backup{
-		Rule:            "some-rule",
-		Id:              123456,
-		ContainerId:     "some-container-id",
-		TempDirectory:   "/tmp/temp_dir",
-		BackupDirectory: "/transfer/some_dir", // this is updated
-		ExecStatus:      ExecStatusSuccess,    // and this is too
-	}).Return(nil)
+	repo.On("Update", ctx, mock.MatchedBy(func(b Backup) bool {
+		return b.Id == backup.Id &&
+			b.BackupFile == "/transfer/some_file.zip" && // this is updated
+			b.ExecStatus == ExecStatusSuccess && // and this is too
+			b.FinishedAt != nil
+	})).Return(nil)
 
 	dockerClient.On("ContainerRemove", mock.Anything, backup.ContainerId, mock.Anything).Return(nil)
 
-	svc := NewBackupService(discardLogger(), repo, dockerClient, mountManager, transferManager)
+	dockerClient.On("ContainerLogs", mock.Anything, backup.ContainerId, mock.Anything).
+		Return(containerLogs("dumping...", "some warning"), nil)
+
+	repo.On("SaveLogs", mock.Anything, backup.Id, "dumping...some warning").Return(nil).Once()
 
-	resultBackup, err := svc.FinishBackup(ctx, backup)
+	svc := NewBackupService(discardLogger(), repo, dockerClient, mountManager, transferManager, nil, nil)
+
+	resultBackup, err := svc.FinishBackup(ctx, Rule{Name: "some-rule"}, backup)
 
 	assert.Nil(t, err)
 	assert.Equal(t, ExecStatusSuccess, resultBackup.ExecStatus)
+	assert.Equal(t, "/transfer/some_file.zip", resultBackup.BackupFile)
+	assert.True(t, resultBackup.BackupSize > 0)
+	repo.AssertExpectations(t)
+}
+
+// Prefixes data with `encrypted:` marker
+type prefixEncrypter struct{}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+func (prefixEncrypter) Encrypt(w io.Writer) (io.WriteCloser, error) {
+	_, err := w.Write([]byte("encrypted:"))
+	return nopWriteCloser{w}, err
+}
+
+func (prefixEncrypter) Extension() string {
+	return ".enc"
+}
+
+func TestService_FinishBackup_Encrypted(t *testing.T) {
+	repo := &backupRepositoryMock{}
+	dockerClient := &dockerClientMock{}
+	mountManager := &mountManagerMock{}
+	transferManager := &transferManagerMock{}
+
+	tempDirectory, err := ioutil.TempDir("", "backuper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDirectory)
+
+	err = ioutil.WriteFile(path.Join(tempDirectory, "dump.sql"), []byte("some dump"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backup := Backup{
+		Rule:          "some-rule",
+		Id:            123456,
+		ContainerId:   "some-container-id",
+		TempDirectory: tempDirectory,
+		ExecStatus:    ExecStatusStarted,
+	}
+
+	ctx := context.Background()
+
+	dockerClient.On("ContainerWait", ctx, backup.ContainerId).Return(int64(0), nil)
+	dockerClient.On("ContainerRemove", mock.Anything, backup.ContainerId, mock.Anything).Return(nil)
+	dockerClient.On("ContainerLogs", mock.Anything, backup.ContainerId, mock.Anything).Return(containerLogs("", ""), nil)
+	repo.On("SaveLogs", mock.Anything, backup.Id, "").Return(nil)
+
+	var transferred []byte
+
+	transferManager.On("Transfer", mock.MatchedBy(func(b Backup) bool {
+		transferred, _ = ioutil.ReadFile(b.TempBackupFile)
+		return b.TempBackupFile == path.Join(tempDirectory, "__backup__.zip.enc")
+	})).Return("/transfer/some_file.zip.enc", nil)
+	repo.On("SaveCopy", ctx, mock.AnythingOfType("BackupCopy")).Return(nil)
+
+	mountManager.On("DeallocateTemp", backup.TempDirectory).Return(nil)
+
+	repo.On("Update", ctx, mock.AnythingOfType("Backup")).Return(nil)
+
+	encrypters := map[string]Encrypter{"some-rule": prefixEncrypter{}}
+
+	svc := NewBackupService(discardLogger(), repo, dockerClient, mountManager, transferManager, encrypters, nil)
+
+	resultBackup, err := svc.FinishBackup(ctx, Rule{Name: "some-rule"}, backup)
+
+	assert.Nil(t, err)
+	assert.Equal(t, ExecStatusSuccess, resultBackup.ExecStatus)
+	assert.Equal(t, int64(len(transferred)), resultBackup.BackupSize)
+	assert.True(t, bytes.HasPrefix(transferred, []byte("encrypted:PK")))
+
+	// unencrypted archive must not be left in temp directory
+	_, err = os.Stat(path.Join(tempDirectory, "__backup__.zip"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestService_UnsetEncrypter(t *testing.T) {
+	svc := NewBackupService(discardLogger(), nil, nil, nil, nil, nil, nil)
+
+	assert.Nil(t, svc.SetEncrypter("some-rule", prefixEncrypter{}))
+
+	// encrypter set by the rule registered again is kept
+	svc.UnsetEncrypter("some-rule", nil)
+
+	encrypter, ok := svc.encrypter("some-rule")
+	assert.True(t, ok)
+	assert.Equal(t, prefixEncrypter{}, encrypter)
+
+	svc.UnsetEncrypter("some-rule", prefixEncrypter{})
+
+	_, ok = svc.encrypter("some-rule")
+	assert.False(t, ok)
+}
+
+func TestService_FinishBackup_Streaming(t *testing.T) {
+	repo := &backupRepositoryMock{}
+	dockerClient := &dockerClientMock{}
+	mountManager := &mountManagerMock{}
+	transferManager := &transferManagerMock{}
+
+	tempDirectory, err := ioutil.TempDir("", "backuper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDirectory)
+
+	err = ioutil.WriteFile(path.Join(tempDirectory, "dump.sql"), []byte("some dump"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backup := Backup{
+		Rule:          "some-rule",
+		Id:            123456,
+		ContainerId:   "some-container-id",
+		TempDirectory: tempDirectory,
+		ExecStatus:    ExecStatusStarted,
+	}
+
+	ctx := context.Background()
+
+	dockerClient.On("ContainerWait", ctx, backup.ContainerId).Return(int64(0), nil)
+	dockerClient.On("ContainerRemove", mock.Anything, backup.ContainerId, mock.Anything).Return(nil)
+	dockerClient.On("ContainerLogs", mock.Anything, backup.ContainerId, mock.Anything).Return(containerLogs("", ""), nil)
+	repo.On("SaveLogs", mock.Anything, backup.Id, "").Return(nil)
+
+	var transferred []byte
+
+	transferManager.On("TransferStream", mock.AnythingOfType("Backup"), ".tar.gz.enc", mock.MatchedBy(func(data []byte) bool {
+		transferred = data
+		return true
+	})).Return("/transfer/some_file.tar.gz.enc", nil)
+	repo.On("SaveCopy", ctx, mock.AnythingOfType("BackupCopy")).Return(nil)
+
+	mountManager.On("DeallocateTemp", backup.TempDirectory).Return(nil)
+
+	repo.On("Update", ctx, mock.AnythingOfType("Backup")).Return(nil)
+
+	encrypters := map[string]Encrypter{"some-rule": prefixEncrypter{}}
+
+	svc := NewBackupService(discardLogger(), repo, dockerClient, mountManager, transferManager, encrypters, nil)
+
+	resultBackup, err := svc.FinishBackup(ctx, Rule{Name: "some-rule", Archive: "tar.gz", Streaming: true}, backup)
+
+	assert.Nil(t, err)
+	assert.Equal(t, ExecStatusSuccess, resultBackup.ExecStatus)
+	assert.Equal(t, "/transfer/some_file.tar.gz.enc", resultBackup.BackupFile)
+	assert.Equal(t, int64(len(transferred)), resultBackup.BackupSize)
+	assert.True(t, bytes.HasPrefix(transferred, []byte("encrypted:\x1f\x8b")))
+	transferManager.AssertNotCalled(t, "Transfer", mock.Anything)
+
+	// nothing but dumped data is left in temp directory
+	files, _ := ioutil.ReadDir(tempDirectory)
+	assert.Len(t, files, 1)
+}
+
+// Stops reading stream part-way as storage failing in the middle of upload does
+type failingStreamTransferManager struct {
+	transferManagerMock
+	err error
+}
+
+func (m *failingStreamTransferManager) TransferStream(backup Backup, ext string, r io.Reader) (string, error) {
+	_, _ = r.Read(make([]byte, 16))
+
+	return "", m.err
+}
+
+func TestService_streamArchive_TransferFailure(t *testing.T) {
+	tempDirectory, err := ioutil.TempDir("", "backuper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDirectory)
+
+	err = ioutil.WriteFile(path.Join(tempDirectory, "dump.sql"), bytes.Repeat([]byte("some dump"), 1024), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	transferManager := &failingStreamTransferManager{err: errors.New("connection reset by peer")}
+
+	svc := NewBackupService(discardLogger(), nil, nil, nil, transferManager, nil, nil)
+
+	format, _ := archive.Get("zip")
+
+	_, err = svc.streamArchive(format, Backup{Rule: "some-rule", TempDirectory: tempDirectory})
+
+	assert.Equal(t, transferManager.err, err)
+}
+
+// endregion
+
+// region Test: RestoreBackup
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = w.Write([]byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := zw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestService_RestoreBackup(t *testing.T) {
+	repo := &backupRepositoryMock{}
+	dockerClient := &dockerClientMock{}
+	mountManager := &mountManagerMock{}
+	transferManager := &transferManagerMock{}
+
+	tempDirectory, err := ioutil.TempDir("", "backuper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDirectory)
+
+	rule := Rule{
+		Name:           "some-rule",
+		Image:          "whatever/image:1.2.3",
+		RestoreCommand: []string{"sh", "-c", "cat $BACKUP_SOURCE_DIR/dump.sql"},
+	}
+
+	backup := Backup{
+		Id:          123456,
+		Rule:        "some-rule",
+		ExecStatus:  ExecStatusSuccess,
+		StorageName: "some-storage",
+		BackupFile:  "/transfer/some_file.zip",
+	}
+
+	ctx := context.Background()
+
+	dockerClient.On("ImagePull", ctx, "docker.io/whatever/image:1.2.3", mock.Anything).
+		Return(ioutil.NopCloser(strings.NewReader("some response")), nil)
+
+	mountManager.On("AllocateTemp").Return(tempDirectory, nil)
+	mountManager.On("DeallocateTemp", tempDirectory).Return(nil)
+
+	// backups made before replication have no copies
+	repo.On("FindCopies", ctx, backup.Id).Return([]BackupCopy{}, nil)
+	transferManager.On("Open", backup).
+		Return(ioutil.NopCloser(bytes.NewReader(zipArchive(t, map[string]string{"dump.sql": "some dump"}))), nil)
+
+	dockerClient.On("ContainerCreate", ctx,
+		&container.Config{
+			Image: "docker.io/whatever/image:1.2.3",
+			Cmd:   rule.RestoreCommand,
+			Env: []string{
+				"BACKUP_SOURCE_DIR=/__restore__",
+			},
+			Labels: map[string]string{"backuper.managed": "true"},
+		},
+		&container.HostConfig{
+			NetworkMode: "host",
+			Mounts: []mount.Mount{
+				{Type: mount.TypeBind, Source: tempDirectory, Target: "/__restore__"},
+			},
+		}, mock.Anything, "restore-some-rule-123456",
+	).Return(container.ContainerCreateCreatedBody{ID: "some-id"}, nil)
+
+	dockerClient.On("ContainerStart", ctx, "some-id", mock.Anything).Return(nil)
+	dockerClient.On("ContainerWait", ctx, "some-id").Return(int64(0), nil)
+	dockerClient.On("ContainerRemove", mock.Anything, "some-id", mock.Anything).Return(nil)
+
+	svc := NewBackupService(discardLogger(), repo, dockerClient, mountManager, transferManager, nil, nil)
+
+	err = svc.RestoreBackup(ctx, rule, backup)
+
+	assert.Nil(t, err)
+	dockerClient.AssertExpectations(t)
+
+	// archive is unpacked and removed
+	data, err := ioutil.ReadFile(path.Join(tempDirectory, "dump.sql"))
+	assert.Nil(t, err)
+	assert.Equal(t, "some dump", string(data))
+
+	_, err = os.Stat(path.Join(tempDirectory, "__restore__.zip"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestService_RestoreBackup_NotRestorable(t *testing.T) {
+	svc := NewBackupService(discardLogger(), nil, nil, nil, &transferManagerMock{}, nil, nil)
+
+	rule := Rule{Name: "some-rule", RestoreCommand: []string{"true"}}
+
+	err := svc.RestoreBackup(context.Background(), Rule{Name: "some-rule"}, Backup{ExecStatus: ExecStatusSuccess})
+	assert.Equal(t, ErrRestoreNotConfigured, err)
+
+	err = svc.RestoreBackup(context.Background(), rule, Backup{ExecStatus: ExecStatusFailure})
+	assert.Equal(t, ErrBackupNotRestorable, err)
+
+	now := time.Now()
+	err = svc.RestoreBackup(context.Background(), rule, Backup{ExecStatus: ExecStatusSuccess, DeletedAt: &now})
+	assert.Equal(t, ErrBackupNotRestorable, err)
 }
 
 // endregion
@@ -0,0 +1,57 @@
+package domain
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/yurykabanov/backuper/pkg/appcontext"
+)
+
+// TransferBackup retries transfer of archive of dumped backup to storage
+func (s *BackupService) TransferBackup(ctx context.Context, rule Rule, backup Backup) (Backup, error) {
+	// Temp directory could have been cleaned up while backuper was stopped
+	if _, err := os.Stat(backup.TempBackupFile); err != nil {
+		// There is nothing to retry without archive, copies stored by previous
+		// attempts are removed as if attempts were exhausted
+		backup.TransferAttempts = rule.Retry.transferAttempts()
+
+		return s.failTransfer(ctx, rule, backup, fmt.Errorf("archive of dumped backup is not available: %s", err))
+	}
+
+	backup.TransferAttempts++
+
+	backup, err := s.transferCopies(ctx, rule, backup)
+	if err != nil {
+		return s.failTransfer(ctx, rule, backup, err)
+	}
+
+	return s.markWithStatusAndDeallocate(backup, ExecStatusSuccess)
+}
+
+// failTransfer keeps archive which couldn't be transferred in temp directory
+// marking backup dumped unless transfer attempts of the rule are exhausted
+func (s *BackupService) failTransfer(ctx context.Context, rule Rule, backup Backup, cause error) (Backup, error) {
+	logger := appcontext.LoggerFromContext(s.logger, ctx)
+
+	if backup.TransferAttempts >= rule.Retry.transferAttempts() {
+		// Copies stored short of quorum would never be swept otherwise
+		if backup.BackupFile != "" {
+			if err := s.removeCopies(ctx, backup); err != nil {
+				logger.WithError(err).Error("BackupService::failTransfer is unable to remove copies of failed backup")
+			}
+		}
+
+		backup, _ = s.markWithStatusAndDeallocate(backup, ExecStatusFailure)
+
+		return backup, cause
+	}
+
+	backup.ExecStatus = ExecStatusDumped
+
+	if err := s.repo.Update(context.Background(), backup); err != nil {
+		logger.WithError(err).Error("BackupService::failTransfer is unable to mark backup dumped")
+	}
+
+	return backup, cause
+}
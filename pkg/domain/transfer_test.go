@@ -0,0 +1,269 @@
+package domain
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestService_FinishBackup_TransferFailed(t *testing.T) {
+	repo := &backupRepositoryMock{}
+	dockerClient := &dockerClientMock{}
+	mountManager := &mountManagerMock{}
+	transferManager := &transferManagerMock{}
+
+	tempDirectory, err := ioutil.TempDir("", "backuper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDirectory)
+
+	err = ioutil.WriteFile(path.Join(tempDirectory, "dump.sql"), []byte("some dump"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backup := Backup{
+		Rule:          "some-rule",
+		Id:            123456,
+		ContainerId:   "some-container-id",
+		TempDirectory: tempDirectory,
+		ExecStatus:    ExecStatusStarted,
+	}
+
+	ctx := context.Background()
+
+	dockerClient.On("ContainerWait", ctx, backup.ContainerId).Return(int64(0), nil)
+	dockerClient.On("ContainerRemove", mock.Anything, backup.ContainerId, mock.Anything).Return(nil)
+	dockerClient.On("ContainerLogs", mock.Anything, backup.ContainerId, mock.Anything).Return(containerLogs("", ""), nil)
+	repo.On("SaveLogs", mock.Anything, backup.Id, "").Return(nil)
+
+	transferManager.On("Transfer", mock.AnythingOfType("Backup")).Return("", errors.New("timeout"))
+
+	repo.On("Update", mock.Anything, mock.MatchedBy(func(b Backup) bool {
+		return b.ExecStatus == ExecStatusDumped && b.TempBackupFile != ""
+	})).Return(nil).Once()
+	repo.On("SaveCopy", mock.Anything, mock.MatchedBy(func(c BackupCopy) bool {
+		return c.ExecStatus == ExecStatusFailure && c.BackupFile == ""
+	})).Return(nil).Once()
+
+	svc := NewBackupService(discardLogger(), repo, dockerClient, mountManager, transferManager, nil, nil)
+
+	// transfer is retried even if the rule has no retry policy
+	rule := Rule{Name: "some-rule"}
+
+	resultBackup, err := svc.FinishBackup(ctx, rule, backup)
+
+	assert.NotNil(t, err)
+	assert.Equal(t, ExecStatusDumped, resultBackup.ExecStatus)
+
+	// archive is kept for retry of transfer
+	_, err = os.Stat(resultBackup.TempBackupFile)
+	assert.Nil(t, err)
+	mountManager.AssertNotCalled(t, "DeallocateTemp", mock.Anything)
+	repo.AssertExpectations(t)
+}
+
+func TestService_TransferBackup(t *testing.T) {
+	repo := &backupRepositoryMock{}
+	mountManager := &mountManagerMock{}
+	transferManager := &transferManagerMock{}
+
+	tempDirectory, err := ioutil.TempDir("", "backuper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tempDirectory)
+
+	archiveFile := path.Join(tempDirectory, "__backup__.zip")
+
+	err = ioutil.WriteFile(archiveFile, []byte("some archive"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backup := Backup{
+		Rule:           "some-rule",
+		Id:             123456,
+		TempDirectory:  tempDirectory,
+		TempBackupFile: archiveFile,
+		ExecStatus:     ExecStatusDumped,
+	}
+
+	rule := Rule{Name: "some-rule", Retry: RetryPolicy{Attempts: 2}}
+
+	ctx := context.Background()
+
+	svc := NewBackupService(discardLogger(), repo, nil, mountManager, transferManager, nil, nil)
+
+	repo.On("FindCopies", mock.Anything, backup.Id).Return([]BackupCopy{}, nil)
+	repo.On("SaveCopy", mock.Anything, mock.AnythingOfType("BackupCopy")).Return(nil)
+
+	// the first retry fails, archive is still kept
+	transferManager.On("Transfer", mock.AnythingOfType("Backup")).Return("", errors.New("timeout")).Once()
+	repo.On("Update", mock.Anything, mock.MatchedBy(func(b Backup) bool {
+		return b.ExecStatus == ExecStatusDumped && b.TransferAttempts == 1
+	})).Return(nil).Once()
+
+	backup, err = svc.TransferBackup(ctx, rule, backup)
+
+	assert.NotNil(t, err)
+	assert.Equal(t, ExecStatusDumped, backup.ExecStatus)
+	mountManager.AssertNotCalled(t, "DeallocateTemp", mock.Anything)
+
+	// the second one succeeds
+	transferManager.On("Transfer", mock.AnythingOfType("Backup")).Return("/transfer/some_file.zip", nil).Once()
+	repo.On("Update", mock.Anything, mock.MatchedBy(func(b Backup) bool {
+		return b.ExecStatus == ExecStatusSuccess && b.TransferAttempts == 2 && b.BackupFile == "/transfer/some_file.zip"
+	})).Return(nil).Once()
+	mountManager.On("DeallocateTemp", tempDirectory).Return(nil).Once()
+
+	backup, err = svc.TransferBackup(ctx, rule, backup)
+
+	assert.Nil(t, err)
+	assert.Equal(t, ExecStatusSuccess, backup.ExecStatus)
+	repo.AssertExpectations(t)
+	mountManager.AssertExpectations(t)
+}
+
+func TestService_TransferBackup_Exhausted(t *testing.T) {
+	repo := &backupRepositoryMock{}
+	mountManager := &mountManagerMock{}
+	transferManager := &transferManagerMock{}
+
+	archiveFile, err := ioutil.TempFile("", "backuper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	archiveFile.Close()
+	defer os.Remove(archiveFile.Name())
+
+	backup := Backup{
+		Rule:             "some-rule",
+		Id:               123456,
+		TempDirectory:    "/tmp/some-dir",
+		TempBackupFile:   archiveFile.Name(),
+		ExecStatus:       ExecStatusDumped,
+		TransferAttempts: 1,
+	}
+
+	transferManager.On("Transfer", mock.AnythingOfType("Backup")).Return("", errors.New("timeout"))
+	repo.On("FindCopies", mock.Anything, backup.Id).Return([]BackupCopy{}, nil)
+	repo.On("SaveCopy", mock.Anything, mock.AnythingOfType("BackupCopy")).Return(nil)
+	repo.On("Update", mock.Anything, mock.MatchedBy(func(b Backup) bool {
+		return b.ExecStatus == ExecStatusFailure && b.FinishedAt != nil
+	})).Return(nil).Once()
+	mountManager.On("DeallocateTemp", "/tmp/some-dir").Return(nil).Once()
+
+	svc := NewBackupService(discardLogger(), repo, nil, mountManager, transferManager, nil, nil)
+
+	backup, err = svc.TransferBackup(context.Background(), Rule{Name: "some-rule", Retry: RetryPolicy{Attempts: 2}}, backup)
+
+	assert.NotNil(t, err)
+	assert.Equal(t, ExecStatusFailure, backup.ExecStatus)
+	repo.AssertExpectations(t)
+	mountManager.AssertExpectations(t)
+}
+
+func TestService_TransferBackup_ArchiveMissing(t *testing.T) {
+	repo := &backupRepositoryMock{}
+	mountManager := &mountManagerMock{}
+	transferManager := &transferManagerMock{}
+
+	backup := Backup{
+		Rule:             "some-rule",
+		Id:               123456,
+		TempDirectory:    "/tmp/some-dir",
+		TempBackupFile:   "/tmp/some-dir/__backup__.zip",
+		BackupFile:       "/local/some_file.zip",
+		ExecStatus:       ExecStatusDumped,
+		TransferAttempts: 1,
+	}
+
+	// the copy stored by the previous attempt is removed
+	repo.On("FindCopies", mock.Anything, backup.Id).Return([]BackupCopy{
+		{BackupId: backup.Id, StorageName: "local", BackupFile: "/local/some_file.zip", ExecStatus: ExecStatusSuccess},
+		{BackupId: backup.Id, StorageName: "remote", ExecStatus: ExecStatusFailure},
+	}, nil)
+	transferManager.On("Remove", mock.MatchedBy(func(b Backup) bool {
+		return b.StorageName == "local" && b.BackupFile == "/local/some_file.zip"
+	})).Return(nil).Once()
+	repo.On("SaveCopy", mock.Anything, mock.MatchedBy(func(c BackupCopy) bool {
+		return c.StorageName == "local" && c.DeletedAt != nil
+	})).Return(nil).Once()
+	repo.On("Update", mock.Anything, mock.MatchedBy(func(b Backup) bool {
+		return b.ExecStatus == ExecStatusFailure && b.FinishedAt != nil
+	})).Return(nil).Once()
+	mountManager.On("DeallocateTemp", "/tmp/some-dir").Return(nil).Once()
+
+	svc := NewBackupService(discardLogger(), repo, nil, mountManager, transferManager, nil, nil)
+
+	backup, err := svc.TransferBackup(context.Background(), Rule{Name: "some-rule", Retry: RetryPolicy{Attempts: 3}}, backup)
+
+	assert.NotNil(t, err)
+	assert.Equal(t, ExecStatusFailure, backup.ExecStatus)
+	transferManager.AssertNotCalled(t, "Transfer", mock.Anything)
+	transferManager.AssertExpectations(t)
+	repo.AssertExpectations(t)
+	mountManager.AssertExpectations(t)
+}
+
+func TestManager_handleRuleBackup_Dumped(t *testing.T) {
+	repo := &backupRepositoryMock{}
+	service := &backupServiceMock{}
+
+	rule := Rule{
+		Name:    "some-rule",
+		Timeout: time.Minute,
+		Retry:   RetryPolicy{Attempts: 2, InitialDelay: time.Millisecond},
+	}
+
+	dumped := Backup{Id: 42, Rule: rule.Name, ExecStatus: ExecStatusDumped, TempBackupFile: "/tmp/some-dir/__backup__.zip"}
+	failed := dumped
+	failed.TransferAttempts = 1
+
+	// dumped backup isn't started again, only transfer is retried
+	service.On("TransferBackup", mock.Anything, rule, dumped).Return(failed, errors.New("timeout")).Once()
+	repo.On("FindAllSuccessfulNotDeletedCopies", mock.Anything, rule, "").Return([]Backup{}, nil)
+
+	// retry of transfer is postponed so it's delayed after restart as well
+	repo.On("Update", mock.Anything, mock.MatchedBy(func(b Backup) bool {
+		return b.Id == failed.Id && b.ExecStatus == ExecStatusDumped && b.RetryAt != nil
+	})).Return(nil).Once()
+
+	m := NewBackupManager(discardLogger(), []Rule{rule}, service, repo, nil)
+
+	m.handleRuleBackup(context.Background(), rule, dumped)
+
+	select {
+	case retry := <-m.active[rule.Name]:
+		assert.Equal(t, failed.TransferAttempts, retry.TransferAttempts)
+		assert.NotNil(t, retry.RetryAt)
+	case <-time.After(time.Second):
+		t.Fatal("retry of transfer has not been enqueued")
+	}
+
+	// the dump isn't repeated after retries of transfer are exhausted
+	exhausted := failed
+	exhausted.ExecStatus = ExecStatusFailure
+	exhausted.TransferAttempts = 2
+	service.On("TransferBackup", mock.Anything, rule, failed).Return(exhausted, errors.New("timeout")).Once()
+
+	m.handleRuleBackup(context.Background(), rule, failed)
+
+	select {
+	case <-m.active[rule.Name]:
+		t.Fatal("backup has been retried after retries of transfer are exhausted")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	service.AssertExpectations(t)
+	repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
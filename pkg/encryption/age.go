@@ -0,0 +1,38 @@
+package encryption
+
+import (
+	"bytes"
+	"io"
+
+	"filippo.io/age"
+)
+
+// AgeEncrypter encrypts data using age (https://age-encryption.org) X25519 recipients
+type AgeEncrypter struct {
+	recipients []age.Recipient
+}
+
+// NewAgeEncrypter parses recipients in format of age recipients file:
+// one public key per line, empty lines and comments (#) are ignored
+func NewAgeEncrypter(keys [][]byte) (*AgeEncrypter, error) {
+	var recipients []age.Recipient
+
+	for _, key := range keys {
+		rr, err := age.ParseRecipients(bytes.NewReader(key))
+		if err != nil {
+			return nil, err
+		}
+
+		recipients = append(recipients, rr...)
+	}
+
+	return &AgeEncrypter{recipients: recipients}, nil
+}
+
+func (e *AgeEncrypter) Encrypt(w io.Writer) (io.WriteCloser, error) {
+	return age.Encrypt(w, e.recipients...)
+}
+
+func (e *AgeEncrypter) Extension() string {
+	return ".age"
+}
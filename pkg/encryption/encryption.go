@@ -0,0 +1,85 @@
+package encryption
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+const (
+	TypeAge     = "age"
+	TypeOpenPGP = "openpgp"
+)
+
+// Config of archive encryption, only public keys of recipients are required
+type Config struct {
+	// Either "age" or "openpgp"
+	Type string `mapstructure:"type"`
+
+	// Recipients specified inline: age public keys ("age1...") or armored OpenPGP public keys
+	Recipients []string `mapstructure:"recipients"`
+
+	// Files with recipients: age recipients files (one key per line) or armored OpenPGP key rings
+	RecipientsFiles []string `mapstructure:"recipients_files"`
+}
+
+// Encrypter encrypts data for the configured recipients
+type Encrypter interface {
+	// Wraps writer so everything written to it is encrypted,
+	// returned writer MUST be closed to flush encrypted data
+	Encrypt(io.Writer) (io.WriteCloser, error)
+
+	// Extension of encrypted files (e.g. ".age")
+	Extension() string
+}
+
+func New(config Config) (Encrypter, error) {
+	keys, err := config.keys()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no recipients specified")
+	}
+
+	switch config.Type {
+	case TypeAge:
+		return NewAgeEncrypter(keys)
+	case TypeOpenPGP:
+		return NewOpenPGPEncrypter(keys)
+	default:
+		return nil, fmt.Errorf("unknown encryption type '%s'", config.Type)
+	}
+}
+
+func (c Config) keys() ([][]byte, error) {
+	var keys [][]byte
+
+	for _, r := range c.Recipients {
+		if strings.TrimSpace(r) != "" {
+			keys = append(keys, []byte(r))
+		}
+	}
+
+	for _, file := range c.RecipientsFiles {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(bytes.TrimSpace(data)) != 0 {
+			keys = append(keys, data)
+		}
+	}
+
+	return keys, nil
+}
+
+// IsEncrypted reports whether file name has extension of any supported encryption
+func IsEncrypted(name string) bool {
+	return strings.HasSuffix(name, (&AgeEncrypter{}).Extension()) ||
+		strings.HasSuffix(name, (&OpenPGPEncrypter{}).Extension())
+}
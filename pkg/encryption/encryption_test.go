@@ -0,0 +1,133 @@
+package encryption
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+func encrypt(t *testing.T, e Encrypter, data []byte) []byte {
+	buf := &bytes.Buffer{}
+
+	w, err := e.Encrypt(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = w.Write(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestAgeEncrypter(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "backuper")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recipientsFile := path.Join(dir, "recipients.txt")
+	err = ioutil.WriteFile(recipientsFile, []byte("# ops team\n"+other.Recipient().String()+"\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := New(Config{
+		Type:            TypeAge,
+		Recipients:      []string{identity.Recipient().String()},
+		RecipientsFiles: []string{recipientsFile},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted := encrypt(t, e, []byte("some archive"))
+
+	// every recipient is able to decrypt archive
+	for _, id := range []age.Identity{identity, other} {
+		r, err := age.Decrypt(bytes.NewReader(encrypted), id)
+		if assert.Nil(t, err) {
+			data, _ := ioutil.ReadAll(r)
+			assert.Equal(t, []byte("some archive"), data)
+		}
+	}
+
+	assert.Equal(t, ".age", e.Extension())
+}
+
+func TestOpenPGPEncrypter(t *testing.T) {
+	entity, err := openpgp.NewEntity("Backuper", "", "backuper@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := &bytes.Buffer{}
+	w, err := armor.Encode(buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = entity.Serialize(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	e, err := New(Config{Type: TypeOpenPGP, Recipients: []string{buf.String()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted := encrypt(t, e, []byte("some archive"))
+
+	md, err := openpgp.ReadMessage(bytes.NewReader(encrypted), openpgp.EntityList{entity}, nil, nil)
+	if assert.Nil(t, err) {
+		data, _ := ioutil.ReadAll(md.UnverifiedBody)
+		assert.Equal(t, []byte("some archive"), data)
+	}
+
+	assert.Equal(t, ".gpg", e.Extension())
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	cases := []Config{
+		{Type: TypeAge},
+		{Type: TypeAge, Recipients: []string{"not a key"}},
+		{Type: TypeOpenPGP, Recipients: []string{"not a key"}},
+		{Type: TypeAge, RecipientsFiles: []string{"/non/existent/file"}},
+		{Type: "rot13", Recipients: []string{"whatever"}},
+	}
+
+	for _, c := range cases {
+		_, err := New(c)
+		assert.NotNil(t, err, "%+v", c)
+	}
+}
+
+func TestIsEncrypted(t *testing.T) {
+	assert.True(t, IsEncrypted("/backups/mysql_2019-01-01_00-00-00.zip.age"))
+	assert.True(t, IsEncrypted("/backups/mysql_2019-01-01_00-00-00.zip.gpg"))
+	assert.False(t, IsEncrypted("/backups/mysql_2019-01-01_00-00-00.zip"))
+}
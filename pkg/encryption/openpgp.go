@@ -0,0 +1,50 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+	// Keys without hash preferences fall back to RIPEMD160 which is not compiled in by default
+	_ "golang.org/x/crypto/ripemd160"
+)
+
+// OpenPGPEncrypter encrypts data for OpenPGP public keys (compatible with GnuPG)
+type OpenPGPEncrypter struct {
+	recipients openpgp.EntityList
+	config     *packet.Config
+}
+
+// NewOpenPGPEncrypter parses armored public keys
+func NewOpenPGPEncrypter(keys [][]byte) (*OpenPGPEncrypter, error) {
+	var recipients openpgp.EntityList
+
+	for _, key := range keys {
+		entities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(key))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read OpenPGP public key: %s", err)
+		}
+
+		recipients = append(recipients, entities...)
+	}
+
+	return &OpenPGPEncrypter{
+		recipients: recipients,
+		config: &packet.Config{
+			DefaultHash:   crypto.SHA256,
+			DefaultCipher: packet.CipherAES256,
+		},
+	}, nil
+}
+
+func (e *OpenPGPEncrypter) Encrypt(w io.Writer) (io.WriteCloser, error) {
+	// Archive is already compressed, so it is neither compressed nor signed
+	return openpgp.Encrypt(w, e.recipients, nil, &openpgp.FileHints{IsBinary: true}, e.config)
+}
+
+func (e *OpenPGPEncrypter) Extension() string {
+	return ".gpg"
+}
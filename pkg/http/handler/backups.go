@@ -0,0 +1,325 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+
+	"github.com/yurykabanov/backuper/pkg/appcontext"
+	"github.com/yurykabanov/backuper/pkg/domain"
+)
+
+const (
+	defaultBackupListLimit = 50
+	maxBackupListLimit     = 1000
+)
+
+type backupResponse struct {
+	Id               int64      `json:"id"`
+	Rule             string     `json:"rule"`
+	ContainerId      string     `json:"container_id"`
+	ExecId           string     `json:"exec_id,omitempty"`
+	TempDirectory    string     `json:"temp_directory"`
+	TargetDirectory  string     `json:"target_directory"`
+	BackupDirectory  string     `json:"backup_directory"`
+	ExecStatus       string     `json:"exec_status"`
+	StatusCode       int64      `json:"status_code"`
+	BackupSize       int64      `json:"backup_size"`
+	Generation       int        `json:"generation"`
+	StorageName      string     `json:"storage_name"`
+	RetryOf          *int64     `json:"retry_of"`
+	Attempt          int        `json:"attempt"`
+	TransferAttempts int        `json:"transfer_attempts"`
+	RetryAt          *time.Time `json:"retry_at"`
+	TempBackupFile   string     `json:"temp_backup_file"`
+	BackupFile       string     `json:"backup_file"`
+	CreatedAt        time.Time  `json:"created_at"`
+	FinishedAt       *time.Time `json:"finished_at"`
+	DeletedAt        *time.Time `json:"deleted_at"`
+
+	// Copies in storages, only single backup is responded with them
+	Copies []backupCopyResponse `json:"copies,omitempty"`
+}
+
+type backupCopyResponse struct {
+	StorageName string     `json:"storage_name"`
+	ExecStatus  string     `json:"exec_status"`
+	BackupFile  string     `json:"backup_file"`
+	CreatedAt   time.Time  `json:"created_at"`
+	DeletedAt   *time.Time `json:"deleted_at"`
+}
+
+func newBackupResponse(b domain.Backup) backupResponse {
+	return backupResponse{
+		Id:               b.Id,
+		Rule:             b.Rule,
+		ContainerId:      b.ContainerId,
+		ExecId:           b.ExecId,
+		TempDirectory:    b.TempDirectory,
+		TargetDirectory:  b.TargetDirectory,
+		BackupDirectory:  b.BackupDirectory,
+		ExecStatus:       b.ExecStatus.String(),
+		StatusCode:       b.StatusCode,
+		BackupSize:       b.BackupSize,
+		Generation:       b.Generation,
+		StorageName:      b.StorageName,
+		RetryOf:          b.RetryOf,
+		Attempt:          b.Attempt,
+		TransferAttempts: b.TransferAttempts,
+		RetryAt:          b.RetryAt,
+		TempBackupFile:   b.TempBackupFile,
+		BackupFile:       b.BackupFile,
+		CreatedAt:        b.CreatedAt,
+		FinishedAt:       b.FinishedAt,
+		DeletedAt:        b.DeletedAt,
+	}
+}
+
+type backupListResponse struct {
+	Items  []backupResponse `json:"items"`
+	Total  int64            `json:"total"`
+	Limit  int              `json:"limit"`
+	Offset int              `json:"offset"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Handles `GET /api/backups`
+type BackupListHandler struct {
+	logger logrus.FieldLogger
+	repo   BackupRepository
+}
+
+func NewBackupListHandler(logger logrus.FieldLogger, repo BackupRepository) *BackupListHandler {
+	return &BackupListHandler{
+		logger: logger,
+		repo:   repo,
+	}
+}
+
+func (h *BackupListHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	logger := appcontext.LoggerFromContext(h.logger, ctx)
+
+	filter, err := parseBackupFilter(r)
+	if err != nil {
+		writeJson(logger, w, http.StatusBadRequest, errorResponse{Error: err.Error()})
+		return
+	}
+
+	bb, err := h.repo.FindByFilter(ctx, filter)
+	if err != nil {
+		logger.WithError(err).Error("Unable to query backups")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	total, err := h.repo.CountByFilter(ctx, filter)
+	if err != nil {
+		logger.WithError(err).Error("Unable to count backups")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	result := backupListResponse{
+		Items:  make([]backupResponse, 0, len(bb)),
+		Total:  total,
+		Limit:  filter.Limit,
+		Offset: filter.Offset,
+	}
+
+	for _, b := range bb {
+		result.Items = append(result.Items, newBackupResponse(b))
+	}
+
+	writeJson(logger, w, http.StatusOK, result)
+}
+
+// Builds filter from query parameters:
+// rule, status, generation, from, to (RFC3339), limit, offset
+func parseBackupFilter(r *http.Request) (domain.BackupFilter, error) {
+	q := r.URL.Query()
+
+	filter := domain.BackupFilter{
+		Rule:  q.Get("rule"),
+		Limit: defaultBackupListLimit,
+	}
+
+	if v := q.Get("status"); v != "" {
+		status, err := domain.ParseExecStatus(v)
+		if err != nil {
+			return filter, err
+		}
+		filter.ExecStatus = &status
+	}
+
+	if v := q.Get("generation"); v != "" {
+		generation, err := strconv.Atoi(v)
+		if err != nil || generation < 0 {
+			return filter, fmt.Errorf("invalid generation '%s'", v)
+		}
+		filter.Generation = &generation
+	}
+
+	var err error
+
+	if v := q.Get("from"); v != "" {
+		filter.CreatedFrom, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid 'from' date '%s', RFC3339 expected", v)
+		}
+	}
+
+	if v := q.Get("to"); v != "" {
+		filter.CreatedTo, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid 'to' date '%s', RFC3339 expected", v)
+		}
+	}
+
+	if v := q.Get("limit"); v != "" {
+		filter.Limit, err = strconv.Atoi(v)
+		if err != nil || filter.Limit <= 0 || filter.Limit > maxBackupListLimit {
+			return filter, fmt.Errorf("invalid limit '%s', expected value between 1 and %d", v, maxBackupListLimit)
+		}
+	}
+
+	if v := q.Get("offset"); v != "" {
+		filter.Offset, err = strconv.Atoi(v)
+		if err != nil || filter.Offset < 0 {
+			return filter, fmt.Errorf("invalid offset '%s'", v)
+		}
+	}
+
+	return filter, nil
+}
+
+// Handles `GET /api/backups/{id}`
+type BackupHandler struct {
+	logger logrus.FieldLogger
+	repo   BackupRepository
+}
+
+func NewBackupHandler(logger logrus.FieldLogger, repo BackupRepository) *BackupHandler {
+	return &BackupHandler{
+		logger: logger,
+		repo:   repo,
+	}
+}
+
+func (h *BackupHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	logger := appcontext.LoggerFromContext(h.logger, ctx)
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeJson(logger, w, http.StatusBadRequest, errorResponse{Error: "invalid backup id"})
+		return
+	}
+
+	b, err := h.repo.FindById(ctx, id)
+	if err == domain.ErrBackupNotFound {
+		writeJson(logger, w, http.StatusNotFound, errorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		logger.WithError(err).WithField("backup_id", id).Error("Unable to query backup")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	copies, err := h.repo.FindCopies(ctx, id)
+	if err != nil {
+		logger.WithError(err).WithField("backup_id", id).Error("Unable to query copies of backup")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	response := newBackupResponse(b)
+
+	for _, c := range copies {
+		response.Copies = append(response.Copies, backupCopyResponse{
+			StorageName: c.StorageName,
+			ExecStatus:  c.ExecStatus.String(),
+			BackupFile:  c.BackupFile,
+			CreatedAt:   c.CreatedAt,
+			DeletedAt:   c.DeletedAt,
+		})
+	}
+
+	writeJson(logger, w, http.StatusOK, response)
+}
+
+// Handles `GET /api/backups/{id}/logs` responding with tail of logs of dumper container
+type BackupLogsHandler struct {
+	logger logrus.FieldLogger
+	repo   BackupRepository
+}
+
+func NewBackupLogsHandler(logger logrus.FieldLogger, repo BackupRepository) *BackupLogsHandler {
+	return &BackupLogsHandler{
+		logger: logger,
+		repo:   repo,
+	}
+}
+
+func (h *BackupLogsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	logger := appcontext.LoggerFromContext(h.logger, ctx)
+
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		writeJson(logger, w, http.StatusBadRequest, errorResponse{Error: "invalid backup id"})
+		return
+	}
+
+	_, err = h.repo.FindById(ctx, id)
+	if err == domain.ErrBackupNotFound {
+		writeJson(logger, w, http.StatusNotFound, errorResponse{Error: err.Error()})
+		return
+	}
+	if err != nil {
+		logger.WithError(err).WithField("backup_id", id).Error("Unable to query backup")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	logs, err := h.repo.FindLogs(ctx, id)
+	if err != nil {
+		logger.WithError(err).WithField("backup_id", id).Error("Unable to query backup logs")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+
+	_, err = w.Write([]byte(logs))
+	if err != nil {
+		logger.WithError(err).Error("Unable to write response")
+	}
+}
+
+func writeJson(logger logrus.FieldLogger, w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		logger.WithError(err).Error("Unable to encode response")
+	}
+}
@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/yurykabanov/backuper/pkg/domain"
+)
+
+// region backupRepositoryMock
+type backupRepositoryMock struct {
+	mock.Mock
+}
+
+func (m *backupRepositoryMock) FindLastSuccessful(ctx context.Context) ([]domain.Backup, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]domain.Backup), args.Error(1)
+}
+
+func (m *backupRepositoryMock) FindById(ctx context.Context, id int64) (domain.Backup, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(domain.Backup), args.Error(1)
+}
+
+func (m *backupRepositoryMock) FindLogs(ctx context.Context, id int64) (string, error) {
+	args := m.Called(ctx, id)
+	return args.String(0), args.Error(1)
+}
+
+func (m *backupRepositoryMock) FindCopies(ctx context.Context, id int64) ([]domain.BackupCopy, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).([]domain.BackupCopy), args.Error(1)
+}
+
+func (m *backupRepositoryMock) FindByFilter(ctx context.Context, filter domain.BackupFilter) ([]domain.Backup, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).([]domain.Backup), args.Error(1)
+}
+
+func (m *backupRepositoryMock) CountByFilter(ctx context.Context, filter domain.BackupFilter) (int64, error) {
+	args := m.Called(ctx, filter)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *backupRepositoryMock) FindLastSuccessfulIncludingDeleted(ctx context.Context) ([]domain.Backup, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]domain.Backup), args.Error(1)
+}
+
+func (m *backupRepositoryMock) CountGrouped(ctx context.Context) ([]domain.BackupCount, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]domain.BackupCount), args.Error(1)
+}
+
+func (m *backupRepositoryMock) CountRetainedCopies(ctx context.Context) ([]domain.BackupCopyCount, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]domain.BackupCopyCount), args.Error(1)
+}
+
+// endregion
+
+func discardLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	return logger
+}
+
+func TestBackupListHandler(t *testing.T) {
+	createdAt, _ := time.Parse(time.RFC3339, "2019-01-01T00:00:00Z")
+	from, _ := time.Parse(time.RFC3339, "2019-01-01T00:00:00+03:00")
+	status := domain.ExecStatusSuccess
+	generation := 1
+
+	expectedFilter := domain.BackupFilter{
+		Rule:        "mysql",
+		ExecStatus:  &status,
+		Generation:  &generation,
+		CreatedFrom: from,
+		Limit:       10,
+		Offset:      20,
+	}
+
+	repo := &backupRepositoryMock{}
+	repo.On("FindByFilter", mock.Anything, expectedFilter).
+		Return([]domain.Backup{{Id: 42, Rule: "mysql", ExecStatus: domain.ExecStatusSuccess, CreatedAt: createdAt}}, nil)
+	repo.On("CountByFilter", mock.Anything, expectedFilter).
+		Return(int64(21), nil)
+
+	h := NewBackupListHandler(discardLogger(), repo)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/api/backups?rule=mysql&status=success&generation=1&from=2019-01-01T00:00:00%2B03:00&limit=10&offset=20", nil)
+
+	h.ServeHTTP(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	repo.AssertExpectations(t)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Nil(t, err)
+	assert.Equal(t, float64(21), response["total"])
+
+	items := response["items"].([]interface{})
+	if assert.Len(t, items, 1) {
+		item := items[0].(map[string]interface{})
+		assert.Equal(t, float64(42), item["id"])
+		assert.Equal(t, "success", item["exec_status"])
+		assert.Equal(t, "2019-01-01T00:00:00Z", item["created_at"])
+	}
+}
+
+func TestBackupListHandler_BadRequest(t *testing.T) {
+	h := NewBackupListHandler(discardLogger(), &backupRepositoryMock{})
+
+	for _, query := range []string{"status=unknown", "generation=x", "from=yesterday", "limit=0", "limit=100000", "offset=-1"} {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/backups?"+query, nil))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestBackupHandler(t *testing.T) {
+	repo := &backupRepositoryMock{}
+	repo.On("FindById", mock.Anything, int64(42)).Return(domain.Backup{Id: 42, Rule: "mysql"}, nil)
+	repo.On("FindById", mock.Anything, int64(43)).Return(domain.Backup{}, domain.ErrBackupNotFound)
+	repo.On("FindCopies", mock.Anything, int64(42)).Return([]domain.BackupCopy{
+		{BackupId: 42, StorageName: "s3", ExecStatus: domain.ExecStatusSuccess, BackupFile: "/bucket/mysql.zip"},
+	}, nil)
+
+	router := mux.NewRouter()
+	router.Handle("/api/backups/{id}", NewBackupHandler(discardLogger(), repo))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/backups/42", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"rule":"mysql"`)
+	assert.Contains(t, w.Body.String(), `"copies":[{"storage_name":"s3","exec_status":"success","backup_file":"/bucket/mysql.zip"`)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/backups/43", nil))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestBackupLogsHandler(t *testing.T) {
+	repo := &backupRepositoryMock{}
+	repo.On("FindById", mock.Anything, int64(42)).Return(domain.Backup{Id: 42, Rule: "mysql"}, nil)
+	repo.On("FindLogs", mock.Anything, int64(42)).Return("mysqldump: Got error: 1045", nil)
+	repo.On("FindById", mock.Anything, int64(43)).Return(domain.Backup{}, domain.ErrBackupNotFound)
+
+	router := mux.NewRouter()
+	router.Handle("/api/backups/{id}/logs", NewBackupLogsHandler(discardLogger(), repo))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/backups/42/logs", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "mysqldump: Got error: 1045", w.Body.String())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/backups/43/logs", nil))
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
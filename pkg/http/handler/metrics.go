@@ -14,15 +14,23 @@ import (
 
 type BackupRepository interface {
 	FindLastSuccessful(context.Context) ([]domain.Backup, error)
+	FindById(context.Context, int64) (domain.Backup, error)
+	FindLogs(context.Context, int64) (string, error)
+	FindCopies(context.Context, int64) ([]domain.BackupCopy, error)
+	FindByFilter(context.Context, domain.BackupFilter) ([]domain.Backup, error)
+	CountByFilter(context.Context, domain.BackupFilter) (int64, error)
+	FindLastSuccessfulIncludingDeleted(context.Context) ([]domain.Backup, error)
+	CountGrouped(context.Context) ([]domain.BackupCount, error)
+	CountRetainedCopies(context.Context) ([]domain.BackupCopyCount, error)
 }
 
 type BackupMetricHandler struct {
 	logger logrus.FieldLogger
-	rules  []domain.Rule
+	rules  RuleLister
 	repo   BackupRepository
 }
 
-func NewBackupMetricHandler(logger logrus.FieldLogger, rules []domain.Rule, repo BackupRepository) *BackupMetricHandler {
+func NewBackupMetricHandler(logger logrus.FieldLogger, rules RuleLister, repo BackupRepository) *BackupMetricHandler {
 	return &BackupMetricHandler{
 		logger: logger,
 		rules:  rules,
@@ -50,9 +58,19 @@ func (h *BackupMetricHandler) ServeHTTP(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	// Backups of rules which are removed (e.g. container has gone) aren't reported
+	registered := make(map[string]bool)
+	for _, rule := range h.rules.Rules() {
+		registered[rule.Name] = true
+	}
+
 	var result []backupMetricResponse
 
 	for _, b := range bb {
+		if !registered[b.Rule] {
+			continue
+		}
+
 		result = append(result, backupMetricResponse{
 			RuleName:         b.Rule,
 			LastSuccessfulAt: b.CreatedAt.UnixNano() / 1e6,
@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/yurykabanov/backuper/pkg/domain"
+)
+
+func TestBackupMetricHandler(t *testing.T) {
+	createdAt, _ := time.Parse(time.RFC3339, "2019-01-01T00:00:00Z")
+	finishedAt := createdAt.Add(90 * time.Second)
+
+	rules := ruleList{{Name: "mysql"}}
+
+	repo := &backupRepositoryMock{}
+	repo.On("FindLastSuccessful", mock.Anything).Return([]domain.Backup{
+		{Rule: "mysql", CreatedAt: createdAt, FinishedAt: &finishedAt, BackupSize: 1024},
+		{Rule: "removed", CreatedAt: createdAt, FinishedAt: &finishedAt, BackupSize: 2048},
+	}, nil)
+
+	h := NewBackupMetricHandler(discardLogger(), rules, repo)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics/backups", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `[
+		{"rule_name": "mysql", "backup_size": 1024, "last_successful_at_mtime": 1546300800000, "last_completion_mtime": 90000}
+	]`, w.Body.String())
+}
@@ -0,0 +1,195 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/yurykabanov/backuper/pkg/appcontext"
+	"github.com/yurykabanov/backuper/pkg/domain"
+)
+
+// Rules might be added or removed at runtime, so they're listed on every request
+type RuleLister interface {
+	Rules() []domain.Rule
+}
+
+// Handles `GET /metrics` exposing per-rule metrics in Prometheus text format
+type PrometheusMetricHandler struct {
+	logger logrus.FieldLogger
+	rules  RuleLister
+	repo   BackupRepository
+}
+
+func NewPrometheusMetricHandler(logger logrus.FieldLogger, rules RuleLister, repo BackupRepository) *PrometheusMetricHandler {
+	return &PrometheusMetricHandler{
+		logger: logger,
+		rules:  rules,
+		repo:   repo,
+	}
+}
+
+type ruleStats struct {
+	failures int64
+	running  int64
+	// Retained copies by storage and generation
+	retained map[string]map[int]int64
+}
+
+func (h *PrometheusMetricHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	logger := appcontext.LoggerFromContext(h.logger, ctx)
+
+	last, err := h.repo.FindLastSuccessfulIncludingDeleted(ctx)
+	if err != nil {
+		logger.WithError(err).Error("Unable to query last successful backups")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	counts, err := h.repo.CountGrouped(ctx)
+	if err != nil {
+		logger.WithError(err).Error("Unable to count backups")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	copyCounts, err := h.repo.CountRetainedCopies(ctx)
+	if err != nil {
+		logger.WithError(err).Error("Unable to count copies of backups")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	lastByRule := make(map[string]domain.Backup, len(last))
+	for _, b := range last {
+		lastByRule[b.Rule] = b
+	}
+
+	rules := h.rules.Rules()
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Name < rules[j].Name })
+
+	stats := make(map[string]*ruleStats, len(rules))
+	for _, rule := range rules {
+		retained := make(map[string]map[int]int64)
+		for _, storage := range rule.StorageNames() {
+			retained[storage] = make(map[int]int64)
+		}
+
+		stats[rule.Name] = &ruleStats{retained: retained}
+	}
+
+	for _, c := range counts {
+		s, ok := stats[c.Rule]
+		if !ok {
+			// rule was removed from configuration
+			continue
+		}
+
+		switch {
+		case c.ExecStatus == domain.ExecStatusFailure:
+			s.failures += c.Count
+		case c.ExecStatus == domain.ExecStatusCreated || c.ExecStatus == domain.ExecStatusStarted || c.ExecStatus == domain.ExecStatusDumped:
+			s.running += c.Count
+		}
+	}
+
+	// Generations are tracked per copy, so retained backups are counted per storage
+	for _, c := range copyCounts {
+		s, ok := stats[c.Rule]
+		if !ok {
+			continue
+		}
+
+		retained, ok := s.retained[c.StorageName]
+		if !ok {
+			// storage was removed from the rule
+			continue
+		}
+
+		retained[c.Generation] += c.Count
+	}
+
+	buf := &bytes.Buffer{}
+
+	writeMetricHeader(buf, "backuper_last_success_timestamp_seconds", "gauge", "Creation time of the last successful backup.")
+	for _, rule := range rules {
+		if b, ok := lastByRule[rule.Name]; ok {
+			writeMetric(buf, "backuper_last_success_timestamp_seconds", ruleLabels(rule.Name), float64(b.CreatedAt.UnixNano())/1e9)
+		}
+	}
+
+	writeMetricHeader(buf, "backuper_last_success_duration_seconds", "gauge", "Duration of the last successful backup.")
+	for _, rule := range rules {
+		if b, ok := lastByRule[rule.Name]; ok && b.FinishedAt != nil {
+			writeMetric(buf, "backuper_last_success_duration_seconds", ruleLabels(rule.Name), b.FinishedAt.Sub(b.CreatedAt).Seconds())
+		}
+	}
+
+	writeMetricHeader(buf, "backuper_last_success_size_bytes", "gauge", "Size of the last successful backup.")
+	for _, rule := range rules {
+		if b, ok := lastByRule[rule.Name]; ok {
+			writeMetric(buf, "backuper_last_success_size_bytes", ruleLabels(rule.Name), float64(b.BackupSize))
+		}
+	}
+
+	writeMetricHeader(buf, "backuper_failures_total", "counter", "Total number of failed backups.")
+	for _, rule := range rules {
+		writeMetric(buf, "backuper_failures_total", ruleLabels(rule.Name), float64(stats[rule.Name].failures))
+	}
+
+	writeMetricHeader(buf, "backuper_running", "gauge", "Number of currently running backups.")
+	for _, rule := range rules {
+		writeMetric(buf, "backuper_running", ruleLabels(rule.Name), float64(stats[rule.Name].running))
+	}
+
+	writeMetricHeader(buf, "backuper_retained_backups", "gauge", "Number of retained successful backups per storage and generation.")
+	for _, rule := range rules {
+		for _, storage := range rule.StorageNames() {
+			retained := stats[rule.Name].retained[storage]
+
+			// every configured generation is exposed even if it is empty
+			generations := len(rule.StorageRotationRules(storage))
+			for g := range retained {
+				if g >= generations {
+					generations = g + 1
+				}
+			}
+
+			for g := 0; g < generations; g++ {
+				labels := ruleLabels(rule.Name) + `,storage="` + labelValueReplacer.Replace(storage) + `",generation="` + strconv.Itoa(g) + `"`
+				writeMetric(buf, "backuper_retained_backups", labels, float64(retained[g]))
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	_, err = buf.WriteTo(w)
+	if err != nil {
+		logger.WithError(err).Error("Unable to write response")
+	}
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func ruleLabels(rule string) string {
+	return `rule="` + labelValueReplacer.Replace(rule) + `"`
+}
+
+func writeMetricHeader(buf *bytes.Buffer, name, typ, help string) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func writeMetric(buf *bytes.Buffer, name, labels string, value float64) {
+	fmt.Fprintf(buf, "%s{%s} %s\n", name, labels, strconv.FormatFloat(value, 'f', -1, 64))
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/yurykabanov/backuper/pkg/domain"
+)
+
+type ruleList []domain.Rule
+
+func (l ruleList) Rules() []domain.Rule {
+	return append([]domain.Rule(nil), l...)
+}
+
+func TestPrometheusMetricHandler(t *testing.T) {
+	createdAt, _ := time.Parse(time.RFC3339, "2019-01-01T00:00:00Z")
+	finishedAt := createdAt.Add(90 * time.Second)
+
+	rules := ruleList{
+		{Name: "postgres", StorageName: "local", RotationRules: []domain.RotationRule{{}}},
+		{
+			Name:          "mysql",
+			Storages:      []string{"local", "s3"},
+			RotationRules: []domain.RotationRule{{}, {}},
+			StorageRotation: []domain.StorageRotation{
+				{Storage: "s3", RotationRules: []domain.RotationRule{{}}},
+			},
+		},
+	}
+
+	repo := &backupRepositoryMock{}
+	repo.On("FindLastSuccessfulIncludingDeleted", mock.Anything).Return([]domain.Backup{
+		{Rule: "mysql", CreatedAt: createdAt, FinishedAt: &finishedAt, BackupSize: 1024},
+	}, nil)
+	repo.On("CountGrouped", mock.Anything).Return([]domain.BackupCount{
+		{Rule: "mysql", ExecStatus: domain.ExecStatusSuccess, Generation: 0, Count: 3},
+		{Rule: "mysql", ExecStatus: domain.ExecStatusSuccess, Generation: 0, Deleted: true, Count: 10},
+		{Rule: "mysql", ExecStatus: domain.ExecStatusFailure, Generation: 0, Count: 2},
+		{Rule: "mysql", ExecStatus: domain.ExecStatusStarted, Generation: 0, Count: 1},
+		{Rule: "removed", ExecStatus: domain.ExecStatusFailure, Generation: 0, Count: 5},
+	}, nil)
+	repo.On("CountRetainedCopies", mock.Anything).Return([]domain.BackupCopyCount{
+		{Rule: "mysql", StorageName: "local", Generation: 0, Count: 3},
+		{Rule: "mysql", StorageName: "local", Generation: 1, Count: 2},
+		{Rule: "mysql", StorageName: "s3", Generation: 0, Count: 1},
+		{Rule: "mysql", StorageName: "removed", Generation: 0, Count: 4},
+	}, nil)
+
+	h := NewPrometheusMetricHandler(discardLogger(), rules, repo)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `# HELP backuper_last_success_timestamp_seconds Creation time of the last successful backup.
+# TYPE backuper_last_success_timestamp_seconds gauge
+backuper_last_success_timestamp_seconds{rule="mysql"} 1546300800
+# HELP backuper_last_success_duration_seconds Duration of the last successful backup.
+# TYPE backuper_last_success_duration_seconds gauge
+backuper_last_success_duration_seconds{rule="mysql"} 90
+# HELP backuper_last_success_size_bytes Size of the last successful backup.
+# TYPE backuper_last_success_size_bytes gauge
+backuper_last_success_size_bytes{rule="mysql"} 1024
+# HELP backuper_failures_total Total number of failed backups.
+# TYPE backuper_failures_total counter
+backuper_failures_total{rule="mysql"} 2
+backuper_failures_total{rule="postgres"} 0
+# HELP backuper_running Number of currently running backups.
+# TYPE backuper_running gauge
+backuper_running{rule="mysql"} 1
+backuper_running{rule="postgres"} 0
+# HELP backuper_retained_backups Number of retained successful backups per storage and generation.
+# TYPE backuper_retained_backups gauge
+backuper_retained_backups{rule="mysql",storage="local",generation="0"} 3
+backuper_retained_backups{rule="mysql",storage="local",generation="1"} 2
+backuper_retained_backups{rule="mysql",storage="s3",generation="0"} 1
+backuper_retained_backups{rule="postgres",storage="local",generation="0"} 0
+`, w.Body.String())
+}
@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/sirupsen/logrus"
+
+	"github.com/yurykabanov/backuper/pkg/appcontext"
+	"github.com/yurykabanov/backuper/pkg/domain"
+)
+
+type BackupTrigger interface {
+	Trigger(ctx context.Context, rule string) (domain.Backup, error)
+}
+
+// Handles `POST /api/rules/{name}/run`
+type RuleRunHandler struct {
+	logger  logrus.FieldLogger
+	trigger BackupTrigger
+}
+
+func NewRuleRunHandler(logger logrus.FieldLogger, trigger BackupTrigger) *RuleRunHandler {
+	return &RuleRunHandler{
+		logger:  logger,
+		trigger: trigger,
+	}
+}
+
+func (h *RuleRunHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	rule := mux.Vars(r)["name"]
+
+	logger := appcontext.LoggerFromContext(h.logger, appcontext.WithRuleName(ctx, rule))
+
+	b, err := h.trigger.Trigger(ctx, rule)
+	switch err {
+	case nil:
+		writeJson(logger, w, http.StatusAccepted, newBackupResponse(b))
+	case domain.ErrRuleNotFound:
+		writeJson(logger, w, http.StatusNotFound, errorResponse{Error: err.Error()})
+	case domain.ErrRuleBusy:
+		writeJson(logger, w, http.StatusConflict, errorResponse{Error: err.Error()})
+	default:
+		logger.WithError(err).Error("Unable to dispatch backup")
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
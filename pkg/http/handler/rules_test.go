@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/yurykabanov/backuper/pkg/domain"
+)
+
+// region backupTriggerMock
+type backupTriggerMock struct {
+	mock.Mock
+}
+
+func (m *backupTriggerMock) Trigger(ctx context.Context, rule string) (domain.Backup, error) {
+	args := m.Called(ctx, rule)
+	return args.Get(0).(domain.Backup), args.Error(1)
+}
+
+// endregion
+
+func TestRuleRunHandler(t *testing.T) {
+	trigger := &backupTriggerMock{}
+	trigger.On("Trigger", mock.Anything, "mysql").Return(domain.Backup{Id: 42, Rule: "mysql"}, nil)
+	trigger.On("Trigger", mock.Anything, "busy").Return(domain.Backup{}, domain.ErrRuleBusy)
+	trigger.On("Trigger", mock.Anything, "missing").Return(domain.Backup{}, domain.ErrRuleNotFound)
+	trigger.On("Trigger", mock.Anything, "broken").Return(domain.Backup{}, errors.New("some error"))
+
+	router := mux.NewRouter()
+	router.Handle("/api/rules/{name}/run", NewRuleRunHandler(discardLogger(), trigger))
+
+	cases := map[string]int{
+		"mysql":   http.StatusAccepted,
+		"busy":    http.StatusConflict,
+		"missing": http.StatusNotFound,
+		"broken":  http.StatusInternalServerError,
+	}
+
+	for rule, status := range cases {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/rules/"+rule+"/run", nil))
+
+		assert.Equal(t, status, w.Code, rule)
+		if status == http.StatusAccepted {
+			assert.Contains(t, w.Body.String(), `"id":42`)
+		}
+	}
+}
@@ -11,13 +11,13 @@ import (
 func TestManager_AllocateDeallocate(t *testing.T) {
 	m := New("/tmp")
 
-	dir, err := m.Allocate()
+	dir, err := m.AllocateTemp()
 
 	assert.Nil(t, err)
 	assert.DirExists(t, dir)
 	assert.True(t, strings.HasPrefix(dir, "/tmp/"))
 
-	err = m.Deallocate(dir)
+	err = m.DeallocateTemp(dir)
 
 	assert.Nil(t, err)
 
@@ -30,7 +30,7 @@ func TestManager_AllocateDeallocate(t *testing.T) {
 func TestManager_Allocate_Error(t *testing.T) {
 	m := New("/bad_directory")
 
-	dir, err := m.Allocate()
+	dir, err := m.AllocateTemp()
 
 	assert.NotNil(t, err)
 	assert.Equal(t, "", dir)
@@ -0,0 +1,141 @@
+package registry
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+)
+
+// Domain of Docker Hub as returned by reference.Domain
+const dockerHub = "docker.io"
+
+// Credentials of single registry
+type Credentials struct {
+	// Domain of registry, e.g. "registry.example.com:5000" or "docker.io"
+	Registry string `mapstructure:"registry"`
+
+	Username      string `mapstructure:"username"`
+	Password      string `mapstructure:"password"`
+	IdentityToken string `mapstructure:"identity_token"`
+}
+
+// Auth provides credentials of registries by their domains
+type Auth struct {
+	credentials map[string]Credentials
+}
+
+func New(credentials []Credentials) *Auth {
+	auth := &Auth{credentials: make(map[string]Credentials, len(credentials))}
+
+	for _, c := range credentials {
+		auth.credentials[normalize(c.Registry)] = c
+	}
+
+	return auth
+}
+
+// Merge adds credentials of registries which aren't known yet
+func (a *Auth) Merge(other *Auth) {
+	for registry, c := range other.credentials {
+		if _, ok := a.credentials[registry]; !ok {
+			a.credentials[registry] = c
+		}
+	}
+}
+
+// EncodedAuth returns credentials of the registry encoded as expected by
+// docker API or empty string if there are no credentials
+func (a *Auth) EncodedAuth(registry string) (string, error) {
+	c, ok := a.credentials[normalize(registry)]
+	if !ok {
+		return "", nil
+	}
+
+	data, err := json.Marshal(types.AuthConfig{
+		Username:      c.Username,
+		Password:      c.Password,
+		IdentityToken: c.IdentityToken,
+		ServerAddress: registry,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return base64.URLEncoding.EncodeToString(data), nil
+}
+
+// Entry of `auths` section of docker config.json
+type dockerConfigAuth struct {
+	Auth          string `json:"auth"`
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	IdentityToken string `json:"identitytoken"`
+}
+
+// LoadDockerConfig reads credentials stored in `auths` section of docker
+// config.json (as written by `docker login`), credential helpers aren't supported
+func LoadDockerConfig(file string) (*Auth, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var config struct {
+		Auths map[string]dockerConfigAuth `json:"auths"`
+	}
+
+	err = json.Unmarshal(data, &config)
+	if err != nil {
+		return nil, err
+	}
+
+	var credentials []Credentials
+
+	for registry, entry := range config.Auths {
+		c := Credentials{
+			Registry:      registry,
+			Username:      entry.Username,
+			Password:      entry.Password,
+			IdentityToken: entry.IdentityToken,
+		}
+
+		if entry.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("invalid auth of registry '%s': %s", registry, err)
+			}
+
+			parts := strings.SplitN(string(decoded), ":", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("invalid auth of registry '%s': expected username:password", registry)
+			}
+
+			c.Username, c.Password = parts[0], parts[1]
+		}
+
+		credentials = append(credentials, c)
+	}
+
+	return New(credentials), nil
+}
+
+// Registries could be specified as URLs (e.g. "https://index.docker.io/v1/")
+func normalize(registry string) string {
+	registry = strings.TrimPrefix(registry, "https://")
+	registry = strings.TrimPrefix(registry, "http://")
+
+	if i := strings.Index(registry, "/"); i >= 0 {
+		registry = registry[:i]
+	}
+
+	switch registry {
+	case "index.docker.io", "registry-1.docker.io":
+		return dockerHub
+	}
+
+	return registry
+}
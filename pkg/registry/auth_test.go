@@ -0,0 +1,93 @@
+package registry
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func decodeAuth(t *testing.T, encoded string) types.AuthConfig {
+	data, err := base64.URLEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var config types.AuthConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		t.Fatal(err)
+	}
+
+	return config
+}
+
+func TestAuth_EncodedAuth(t *testing.T) {
+	auth := New([]Credentials{
+		{Registry: "registry.example.com:5000", Username: "backuper", Password: "secret"},
+		{Registry: "https://index.docker.io/v1/", IdentityToken: "some-token"},
+	})
+
+	encoded, err := auth.EncodedAuth("registry.example.com:5000")
+	assert.Nil(t, err)
+	assert.Equal(t, types.AuthConfig{
+		Username:      "backuper",
+		Password:      "secret",
+		ServerAddress: "registry.example.com:5000",
+	}, decodeAuth(t, encoded))
+
+	// Docker Hub is known under several names
+	encoded, err = auth.EncodedAuth("docker.io")
+	assert.Nil(t, err)
+	assert.Equal(t, "some-token", decodeAuth(t, encoded).IdentityToken)
+
+	encoded, err = auth.EncodedAuth("quay.io")
+	assert.Nil(t, err)
+	assert.Equal(t, "", encoded)
+}
+
+func TestLoadDockerConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backuper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := path.Join(dir, "config.json")
+	config := `{
+		"auths": {
+			"registry.example.com": {"auth": "` + base64.StdEncoding.EncodeToString([]byte("ci:pa:ss")) + `"},
+			"quay.io": {"auth": "` + base64.StdEncoding.EncodeToString([]byte("robot:token")) + `"}
+		},
+		"credsStore": "desktop"
+	}`
+	if err := ioutil.WriteFile(file, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	fromFile, err := LoadDockerConfig(file)
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	// credentials from configuration take precedence
+	auth := New([]Credentials{{Registry: "quay.io", Username: "backuper", Password: "secret"}})
+	auth.Merge(fromFile)
+
+	encoded, _ := auth.EncodedAuth("registry.example.com")
+	c := decodeAuth(t, encoded)
+	assert.Equal(t, "ci", c.Username)
+	assert.Equal(t, "pa:ss", c.Password)
+
+	encoded, _ = auth.EncodedAuth("quay.io")
+	assert.Equal(t, "backuper", decodeAuth(t, encoded).Username)
+}
+
+func TestLoadDockerConfig_Invalid(t *testing.T) {
+	_, err := LoadDockerConfig("/nonexistent/config.json")
+	assert.NotNil(t, err)
+}
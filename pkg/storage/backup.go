@@ -2,6 +2,9 @@ package storage
 
 import (
 	"context"
+	"database/sql"
+	"fmt"
+	"strings"
 
 	"github.com/jmoiron/sqlx"
 
@@ -11,23 +14,25 @@ import (
 const (
 	backupInsertQuery = `
 		INSERT INTO backups (
-			rule, container_id,
+			rule, container_id, exec_id,
 			temp_directory, target_directory, backup_directory,
 			exec_status, status_code, 
       backup_size, generation,
 			storage_name, temp_backup_file, backup_file,
+			retry_of, attempt, transfer_attempts, retry_at,
 			created_at, finished_at, deleted_at
 		)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
 	backupUpdateQuery = `
 		UPDATE backups SET
-			rule = ?, container_id = ?,
+			rule = ?, container_id = ?, exec_id = ?,
 			temp_directory = ?, target_directory = ?, backup_directory = ?,
 			exec_status = ?, status_code = ?, 
 			backup_size = ?, generation = ?,
 			storage_name = ?, temp_backup_file = ?, backup_file = ?,
+			retry_of = ?, attempt = ?, transfer_attempts = ?, retry_at = ?,
 			created_at = ?, finished_at = ?, deleted_at = ?
 		WHERE id = ?
 	`
@@ -35,30 +40,134 @@ const (
 	backupSelectUnfinished = `
 		SELECT 
 			id,
-			rule, container_id,
+			rule, container_id, exec_id,
 			temp_directory, target_directory, backup_directory,
 			exec_status, status_code, 
       backup_size, generation,
 			storage_name, temp_backup_file, backup_file,
+			retry_of, attempt, transfer_attempts, retry_at,
 			created_at, finished_at, deleted_at
 		FROM backups
 		WHERE exec_status IN (?)
 	`
 
-	backupSelectSuccessfulNotDeleted = `
+	backupSelectById = `
 		SELECT
 			id,
-			rule, container_id,
-			temp_directory, target_directory,
-			exec_status, status_code, 
-      backup_size, generation,
+			rule, container_id, exec_id,
+			temp_directory, target_directory, backup_directory,
+			exec_status, status_code,
+			backup_size, generation,
+			storage_name, temp_backup_file, backup_file,
+			retry_of, attempt, transfer_attempts, retry_at,
+			created_at, finished_at, deleted_at
+		FROM backups
+		WHERE id = ?
+	`
+
+	backupSelectLastSuccessfulIncludingDeleted = `
+		SELECT b.*
+		FROM backups b
+		INNER JOIN (
+			SELECT rule, max(id) AS max_id
+			FROM backups
+			WHERE exec_status = 4
+			GROUP BY rule
+		) bb ON b.id = bb.max_id
+	`
+
+	backupCountGrouped = `
+		SELECT
+			rule, exec_status, generation,
+			deleted_at IS NOT NULL AS deleted,
+			count(*) AS count
+		FROM backups
+		GROUP BY rule, exec_status, generation, deleted_at IS NOT NULL
+	`
+
+	backupCopyCountRetained = `
+		SELECT
+			b.rule, c.storage_name, c.generation,
+			count(*) AS count
+		FROM backup_copies c
+		INNER JOIN backups b ON b.id = c.backup_id
+		WHERE b.exec_status = 4
+			AND c.exec_status = 4
+			AND c.deleted_at IS NULL
+		GROUP BY b.rule, c.storage_name, c.generation
+	`
+
+	backupSelectFiltered = `
+		SELECT
+			id,
+			rule, container_id, exec_id,
+			temp_directory, target_directory, backup_directory,
+			exec_status, status_code,
+			backup_size, generation,
 			storage_name, temp_backup_file, backup_file,
+			retry_of, attempt, transfer_attempts, retry_at,
 			created_at, finished_at, deleted_at
 		FROM backups
-		WHERE rule = ? 
-			AND exec_status = 4
-			AND deleted_at IS NULL
-		ORDER BY created_at ASC
+		WHERE %s
+		ORDER BY julianday(created_at) DESC, id DESC
+		LIMIT ? OFFSET ?
+	`
+
+	backupCountFiltered = `
+		SELECT count(*)
+		FROM backups
+		WHERE %s
+	`
+
+	backupSelectSuccessfulNotDeletedCopies = `
+		SELECT
+			b.id,
+			b.rule, b.container_id, b.exec_id,
+			b.temp_directory, b.target_directory,
+			b.exec_status, b.status_code,
+			b.backup_size, c.generation,
+			c.storage_name, b.temp_backup_file, c.backup_file,
+			b.retry_of, b.attempt, b.transfer_attempts, b.retry_at,
+			b.created_at, b.finished_at, c.deleted_at
+		FROM backup_copies c
+		INNER JOIN backups b ON b.id = c.backup_id
+		WHERE b.rule = ?
+			AND c.storage_name = ?
+			AND b.exec_status = 4
+			AND c.exec_status = 4
+			AND c.deleted_at IS NULL
+		ORDER BY julianday(b.created_at) ASC, b.id ASC
+	`
+
+	// Generation of backup itself follows generation of the copy it refers to
+	backupCopyUpdateGeneration = `
+		UPDATE backup_copies SET generation = ? WHERE backup_id = ? AND storage_name = ?;
+		UPDATE backups SET generation = ? WHERE id = ? AND storage_name = ?;
+	`
+
+	backupLogsUpsertQuery = `
+		INSERT OR REPLACE INTO backup_logs (backup_id, logs)
+		VALUES (?, ?)
+	`
+
+	backupLogsSelectByBackupId = `
+		SELECT logs
+		FROM backup_logs
+		WHERE backup_id = ?
+	`
+
+	backupCopyUpsertQuery = `
+		INSERT OR REPLACE INTO backup_copies (
+			backup_id, storage_name, exec_status, backup_file, generation, created_at, deleted_at
+		)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`
+
+	backupCopiesSelectByBackupId = `
+		SELECT backup_id, storage_name, exec_status, backup_file, generation, created_at, deleted_at
+		FROM backup_copies
+		WHERE backup_id = ?
+		ORDER BY julianday(created_at) ASC, storage_name ASC
 	`
 
 	backupSelectLastFinished = `
@@ -92,11 +201,12 @@ func (r *BackupRepository) Create(ctx context.Context, backup domain.Backup) (do
 
 	res, err := stmt.ExecContext(
 		ctx,
-		backup.Rule, backup.ContainerId,
+		backup.Rule, backup.ContainerId, backup.ExecId,
 		backup.TempDirectory, backup.TargetDirectory, backup.BackupDirectory,
 		backup.ExecStatus, backup.StatusCode,
 		backup.BackupSize, backup.Generation,
 		backup.StorageName, backup.TempBackupFile, backup.BackupFile,
+		backup.RetryOf, backup.Attempt, backup.TransferAttempts, backup.RetryAt,
 		backup.CreatedAt, backup.FinishedAt, backup.DeletedAt,
 	)
 	if err != nil {
@@ -121,11 +231,12 @@ func (r *BackupRepository) Update(ctx context.Context, backup domain.Backup) err
 
 	_, err = stmt.ExecContext(
 		ctx,
-		backup.Rule, backup.ContainerId,
+		backup.Rule, backup.ContainerId, backup.ExecId,
 		backup.TempDirectory, backup.TargetDirectory, backup.BackupDirectory,
 		backup.ExecStatus, backup.StatusCode,
 		backup.BackupSize, backup.Generation,
 		backup.StorageName, backup.TempBackupFile, backup.BackupFile,
+		backup.RetryOf, backup.Attempt, backup.TransferAttempts, backup.RetryAt,
 		backup.CreatedAt, backup.FinishedAt, backup.DeletedAt,
 		backup.Id,
 	)
@@ -133,6 +244,123 @@ func (r *BackupRepository) Update(ctx context.Context, backup domain.Backup) err
 	return err
 }
 
+func (r *BackupRepository) FindById(ctx context.Context, id int64) (domain.Backup, error) {
+	var backup domain.Backup
+
+	err := r.db.GetContext(ctx, &backup, backupSelectById, id)
+	if err == sql.ErrNoRows {
+		return backup, domain.ErrBackupNotFound
+	}
+	if err != nil {
+		return backup, err
+	}
+
+	return backup, nil
+}
+
+// SaveLogs stores (or replaces) logs of dumper container of given backup
+func (r *BackupRepository) SaveLogs(ctx context.Context, backupId int64, logs string) error {
+	_, err := r.db.ExecContext(ctx, backupLogsUpsertQuery, backupId, logs)
+
+	return err
+}
+
+// FindLogs returns logs of dumper container of given backup, backups without
+// stored logs have empty ones
+func (r *BackupRepository) FindLogs(ctx context.Context, backupId int64) (string, error) {
+	var logs string
+
+	err := r.db.GetContext(ctx, &logs, backupLogsSelectByBackupId, backupId)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+
+	return logs, err
+}
+
+// SaveCopy stores (or replaces) copy of backup in storage
+func (r *BackupRepository) SaveCopy(ctx context.Context, c domain.BackupCopy) error {
+	_, err := r.db.ExecContext(
+		ctx,
+		backupCopyUpsertQuery,
+		c.BackupId, c.StorageName, c.ExecStatus, c.BackupFile, c.Generation, c.CreatedAt, c.DeletedAt,
+	)
+
+	return err
+}
+
+// FindCopies returns copies of given backup in order they were transferred
+func (r *BackupRepository) FindCopies(ctx context.Context, backupId int64) ([]domain.BackupCopy, error) {
+	copies := []domain.BackupCopy{}
+
+	err := r.db.SelectContext(ctx, &copies, backupCopiesSelectByBackupId, backupId)
+	if err != nil {
+		return nil, err
+	}
+
+	return copies, nil
+}
+
+func (r *BackupRepository) FindByFilter(ctx context.Context, filter domain.BackupFilter) ([]domain.Backup, error) {
+	where, args := filterConditions(filter)
+	args = append(args, filter.Limit, filter.Offset)
+
+	backups := []domain.Backup{}
+
+	err := r.db.SelectContext(ctx, &backups, fmt.Sprintf(backupSelectFiltered, where), args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return backups, nil
+}
+
+func (r *BackupRepository) CountByFilter(ctx context.Context, filter domain.BackupFilter) (int64, error) {
+	where, args := filterConditions(filter)
+
+	var count int64
+
+	err := r.db.GetContext(ctx, &count, fmt.Sprintf(backupCountFiltered, where), args...)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func filterConditions(filter domain.BackupFilter) (string, []interface{}) {
+	conditions := []string{"1 = 1"}
+	var args []interface{}
+
+	if filter.Rule != "" {
+		conditions = append(conditions, "rule = ?")
+		args = append(args, filter.Rule)
+	}
+
+	if filter.ExecStatus != nil {
+		conditions = append(conditions, "exec_status = ?")
+		args = append(args, *filter.ExecStatus)
+	}
+
+	if filter.Generation != nil {
+		conditions = append(conditions, "generation = ?")
+		args = append(args, *filter.Generation)
+	}
+
+	// Timestamps are stored with local timezone offset, so they're compared as julian days
+	if !filter.CreatedFrom.IsZero() {
+		conditions = append(conditions, "julianday(created_at) >= julianday(?)")
+		args = append(args, filter.CreatedFrom)
+	}
+
+	if !filter.CreatedTo.IsZero() {
+		conditions = append(conditions, "julianday(created_at) < julianday(?)")
+		args = append(args, filter.CreatedTo)
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
 func (r *BackupRepository) FindAllUnfinished(ctx context.Context) ([]domain.Backup, error) {
 	query, args, err := sqlx.In(backupSelectUnfinished, domain.ExecStatusUnfinished)
 	if err != nil {
@@ -150,10 +378,13 @@ func (r *BackupRepository) FindAllUnfinished(ctx context.Context) ([]domain.Back
 	return backups, nil
 }
 
-func (r *BackupRepository) FindAllSuccessfulNotDeleted(ctx context.Context, rule domain.Rule) ([]domain.Backup, error) {
+// FindAllSuccessfulNotDeletedCopies returns successful backups of the rule
+// having copy in given storage, generation, file and deletion time of backups
+// are ones of the copy
+func (r *BackupRepository) FindAllSuccessfulNotDeletedCopies(ctx context.Context, rule domain.Rule, storage string) ([]domain.Backup, error) {
 	var backups []domain.Backup
 
-	err := r.db.SelectContext(ctx, &backups, backupSelectSuccessfulNotDeleted, rule.Name)
+	err := r.db.SelectContext(ctx, &backups, backupSelectSuccessfulNotDeletedCopies, rule.Name, storage)
 	if err != nil {
 		return nil, err
 	}
@@ -161,6 +392,17 @@ func (r *BackupRepository) FindAllSuccessfulNotDeleted(ctx context.Context, rule
 	return backups, nil
 }
 
+func (r *BackupRepository) UpdateCopyGeneration(ctx context.Context, backupId int64, storage string, generation int) error {
+	_, err := r.db.ExecContext(
+		ctx,
+		backupCopyUpdateGeneration,
+		generation, backupId, storage,
+		generation, backupId, storage,
+	)
+
+	return err
+}
+
 func (r *BackupRepository) FindLastSuccessful(ctx context.Context) ([]domain.Backup, error) {
 	var backups []domain.Backup
 
@@ -171,3 +413,38 @@ func (r *BackupRepository) FindLastSuccessful(ctx context.Context) ([]domain.Bac
 
 	return backups, nil
 }
+
+// FindLastSuccessfulIncludingDeleted returns last successful backup (even if it is deleted already) of every rule
+func (r *BackupRepository) FindLastSuccessfulIncludingDeleted(ctx context.Context) ([]domain.Backup, error) {
+	var backups []domain.Backup
+
+	err := r.db.SelectContext(ctx, &backups, backupSelectLastSuccessfulIncludingDeleted)
+	if err != nil {
+		return nil, err
+	}
+
+	return backups, nil
+}
+
+func (r *BackupRepository) CountGrouped(ctx context.Context) ([]domain.BackupCount, error) {
+	var counts []domain.BackupCount
+
+	err := r.db.SelectContext(ctx, &counts, backupCountGrouped)
+	if err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
+
+// CountRetainedCopies counts stored copies of successful backups per storage and generation
+func (r *BackupRepository) CountRetainedCopies(ctx context.Context) ([]domain.BackupCopyCount, error) {
+	var counts []domain.BackupCopyCount
+
+	err := r.db.SelectContext(ctx, &counts, backupCopyCountRetained)
+	if err != nil {
+		return nil, err
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,357 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	migratesqlite "github.com/golang-migrate/migrate/v4/database/sqlite3"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yurykabanov/backuper/pkg/domain"
+	"github.com/yurykabanov/backuper/pkg/util"
+)
+
+func newTestDB(t *testing.T) *sqlx.DB {
+	db, err := sqlx.Open("sqlite3", ":memory:?parseTime=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every connection to in-memory database has its own database
+	db.SetMaxOpenConns(1)
+	db.MapperFunc(util.CamelToSnakeCase)
+
+	driver, err := migratesqlite.WithInstance(db.DB, &migratesqlite.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := migrate.NewWithDatabaseInstance("file://../../migrations", "backuper", driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestBackupRepository_FindByFilter(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+
+	repo := NewBackupRepository(db)
+	ctx := context.Background()
+
+	base, _ := time.Parse(time.RFC3339, "2019-01-01T00:00:00Z")
+	// timestamps are compared regardless of timezone they're stored with
+	moscow := time.FixedZone("MSK", 3*60*60)
+
+	fixtures := []domain.Backup{
+		{Rule: "mysql", ExecStatus: domain.ExecStatusSuccess, Generation: 0, CreatedAt: base},
+		{Rule: "mysql", ExecStatus: domain.ExecStatusFailure, Generation: 0, CreatedAt: base.Add(1 * time.Hour).In(moscow)},
+		{Rule: "mysql", ExecStatus: domain.ExecStatusSuccess, Generation: 1, CreatedAt: base.Add(2 * time.Hour)},
+		{Rule: "postgres", ExecStatus: domain.ExecStatusSuccess, Generation: 0, CreatedAt: base.Add(3 * time.Hour).In(moscow)},
+	}
+
+	for _, b := range fixtures {
+		_, err := repo.Create(ctx, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	success := domain.ExecStatusSuccess
+	generation := 0
+
+	cases := []struct {
+		filter   domain.BackupFilter
+		expected []int64
+	}{
+		{domain.BackupFilter{Limit: 10}, []int64{4, 3, 2, 1}},
+		{domain.BackupFilter{Limit: 2, Offset: 1}, []int64{3, 2}},
+		{domain.BackupFilter{Rule: "mysql", Limit: 10}, []int64{3, 2, 1}},
+		{domain.BackupFilter{Rule: "mysql", ExecStatus: &success, Limit: 10}, []int64{3, 1}},
+		{domain.BackupFilter{Generation: &generation, Limit: 10}, []int64{4, 2, 1}},
+		{domain.BackupFilter{CreatedFrom: base.Add(time.Hour), CreatedTo: base.Add(3 * time.Hour), Limit: 10}, []int64{3, 2}},
+	}
+
+	for _, c := range cases {
+		bb, err := repo.FindByFilter(ctx, c.filter)
+		assert.Nil(t, err)
+
+		var ids []int64
+		for _, b := range bb {
+			ids = append(ids, b.Id)
+		}
+		assert.Equal(t, c.expected, ids, "%+v", c.filter)
+	}
+
+	total, err := repo.CountByFilter(ctx, domain.BackupFilter{Rule: "mysql", Limit: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), total)
+}
+
+func TestBackupRepository_FindById_NotFound(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+
+	_, err := NewBackupRepository(db).FindById(context.Background(), 42)
+
+	assert.Equal(t, domain.ErrBackupNotFound, err)
+}
+
+func TestBackupRepository_Stats(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+
+	repo := NewBackupRepository(db)
+	ctx := context.Background()
+
+	base, _ := time.Parse(time.RFC3339, "2019-01-01T00:00:00Z")
+	finishedAt := base.Add(time.Minute)
+	deletedAt := base.Add(time.Hour)
+
+	fixtures := []domain.Backup{
+		{Rule: "mysql", ExecStatus: domain.ExecStatusSuccess, Generation: 1, CreatedAt: base, FinishedAt: &finishedAt, DeletedAt: &deletedAt},
+		{Rule: "mysql", ExecStatus: domain.ExecStatusSuccess, Generation: 1, CreatedAt: base, FinishedAt: &finishedAt},
+		{Rule: "mysql", ExecStatus: domain.ExecStatusSuccess, Generation: 0, CreatedAt: base, FinishedAt: &finishedAt, BackupSize: 42},
+		{Rule: "mysql", ExecStatus: domain.ExecStatusFailure, CreatedAt: base},
+		{Rule: "postgres", ExecStatus: domain.ExecStatusFailure, CreatedAt: base},
+	}
+
+	for _, b := range fixtures {
+		_, err := repo.Create(ctx, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	last, err := repo.FindLastSuccessfulIncludingDeleted(ctx)
+	assert.Nil(t, err)
+	if assert.Len(t, last, 1) {
+		assert.Equal(t, int64(3), last[0].Id)
+		assert.Equal(t, int64(42), last[0].BackupSize)
+	}
+
+	counts, err := repo.CountGrouped(ctx)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []domain.BackupCount{
+		{Rule: "mysql", ExecStatus: domain.ExecStatusSuccess, Generation: 1, Deleted: true, Count: 1},
+		{Rule: "mysql", ExecStatus: domain.ExecStatusSuccess, Generation: 1, Count: 1},
+		{Rule: "mysql", ExecStatus: domain.ExecStatusSuccess, Generation: 0, Count: 1},
+		{Rule: "mysql", ExecStatus: domain.ExecStatusFailure, Generation: 0, Count: 1},
+		{Rule: "postgres", ExecStatus: domain.ExecStatusFailure, Generation: 0, Count: 1},
+	}, counts)
+}
+
+func TestBackupRepository_Logs(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+
+	repo := NewBackupRepository(db)
+	ctx := context.Background()
+
+	backup, err := repo.Create(ctx, domain.Backup{Rule: "mysql", CreatedAt: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logs, err := repo.FindLogs(ctx, backup.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, "", logs)
+
+	assert.Nil(t, repo.SaveLogs(ctx, backup.Id, "first"))
+	assert.Nil(t, repo.SaveLogs(ctx, backup.Id, "second"))
+
+	logs, err = repo.FindLogs(ctx, backup.Id)
+	assert.Nil(t, err)
+	assert.Equal(t, "second", logs)
+}
+
+func TestBackupRepository_Retry(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+
+	repo := NewBackupRepository(db)
+	ctx := context.Background()
+
+	original, err := repo.Create(ctx, domain.Backup{Rule: "mysql", ExecStatus: domain.ExecStatusFailure, CreatedAt: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	retryAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+
+	retry, err := repo.Create(ctx, domain.Backup{Rule: "mysql", RetryOf: &original.Id, Attempt: 1, TransferAttempts: 2, RetryAt: &retryAt, CreatedAt: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// pending retry is resumed after restart
+	unfinished, err := repo.FindAllUnfinished(ctx)
+	assert.Nil(t, err)
+	if assert.Len(t, unfinished, 1) && assert.NotNil(t, unfinished[0].RetryAt) {
+		assert.True(t, retryAt.Equal(*unfinished[0].RetryAt))
+	}
+
+	found, err := repo.FindById(ctx, retry.Id)
+	assert.Nil(t, err)
+	if assert.NotNil(t, found.RetryOf) {
+		assert.Equal(t, original.Id, *found.RetryOf)
+	}
+	assert.Equal(t, 1, found.Attempt)
+	assert.Equal(t, 2, found.TransferAttempts)
+
+	found, err = repo.FindById(ctx, original.Id)
+	assert.Nil(t, err)
+	assert.Nil(t, found.RetryOf)
+}
+
+func TestBackupRepository_Copies(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+
+	repo := NewBackupRepository(db)
+	ctx := context.Background()
+
+	backup, err := repo.Create(ctx, domain.Backup{Rule: "mysql", ExecStatus: domain.ExecStatusSuccess, CreatedAt: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+
+	assert.Nil(t, repo.SaveCopy(ctx, domain.BackupCopy{BackupId: backup.Id, StorageName: "s3", ExecStatus: domain.ExecStatusFailure, CreatedAt: now}))
+	assert.Nil(t, repo.SaveCopy(ctx, domain.BackupCopy{BackupId: backup.Id, StorageName: "local", ExecStatus: domain.ExecStatusSuccess, BackupFile: "/backups/mysql.zip", CreatedAt: now.Add(-time.Minute)}))
+
+	// retried transfer replaces failed copy
+	assert.Nil(t, repo.SaveCopy(ctx, domain.BackupCopy{BackupId: backup.Id, StorageName: "s3", ExecStatus: domain.ExecStatusSuccess, BackupFile: "/bucket/mysql.zip", CreatedAt: now.Add(time.Minute)}))
+
+	copies, err := repo.FindCopies(ctx, backup.Id)
+	assert.Nil(t, err)
+	if assert.Len(t, copies, 2) {
+		assert.Equal(t, "local", copies[0].StorageName)
+		assert.Equal(t, "/backups/mysql.zip", copies[0].BackupFile)
+		assert.Equal(t, "s3", copies[1].StorageName)
+		assert.Equal(t, domain.ExecStatusSuccess, copies[1].ExecStatus)
+		assert.Nil(t, copies[1].DeletedAt)
+	}
+
+	copies, err = repo.FindCopies(ctx, backup.Id+1)
+	assert.Nil(t, err)
+	assert.Empty(t, copies)
+}
+
+func TestBackupRepository_FindAllSuccessfulNotDeletedCopies(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+
+	repo := NewBackupRepository(db)
+	ctx := context.Background()
+	rule := domain.Rule{Name: "mysql", Storages: []string{"local", "s3"}}
+
+	now := time.Now()
+
+	var ids []int64
+	for i := 0; i < 3; i++ {
+		b, err := repo.Create(ctx, domain.Backup{
+			Rule:        "mysql",
+			ExecStatus:  domain.ExecStatusSuccess,
+			StorageName: "local",
+			BackupFile:  "/backups/mysql.zip",
+			CreatedAt:   now.Add(time.Duration(i) * time.Hour),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, b.Id)
+
+		assert.Nil(t, repo.SaveCopy(ctx, domain.BackupCopy{BackupId: b.Id, StorageName: "local", ExecStatus: domain.ExecStatusSuccess, BackupFile: "/backups/mysql.zip", CreatedAt: now}))
+	}
+
+	// copy of the first backup in s3 has been deleted, the second one has failed
+	assert.Nil(t, repo.SaveCopy(ctx, domain.BackupCopy{BackupId: ids[0], StorageName: "s3", ExecStatus: domain.ExecStatusSuccess, BackupFile: "/bucket/mysql.zip", CreatedAt: now, DeletedAt: &now}))
+	assert.Nil(t, repo.SaveCopy(ctx, domain.BackupCopy{BackupId: ids[1], StorageName: "s3", ExecStatus: domain.ExecStatusFailure, CreatedAt: now}))
+	assert.Nil(t, repo.SaveCopy(ctx, domain.BackupCopy{BackupId: ids[2], StorageName: "s3", ExecStatus: domain.ExecStatusSuccess, BackupFile: "/bucket/mysql.zip", CreatedAt: now}))
+
+	assert.Nil(t, repo.UpdateCopyGeneration(ctx, ids[0], "local", 1))
+	assert.Nil(t, repo.UpdateCopyGeneration(ctx, ids[2], "s3", 2))
+
+	local, err := repo.FindAllSuccessfulNotDeletedCopies(ctx, rule, "local")
+	assert.Nil(t, err)
+	if assert.Len(t, local, 3) {
+		assert.Equal(t, ids[0], local[0].Id)
+		assert.Equal(t, 1, local[0].Generation)
+		assert.Equal(t, "local", local[0].StorageName)
+	}
+
+	s3, err := repo.FindAllSuccessfulNotDeletedCopies(ctx, rule, "s3")
+	assert.Nil(t, err)
+	if assert.Len(t, s3, 1) {
+		assert.Equal(t, ids[2], s3[0].Id)
+		assert.Equal(t, 2, s3[0].Generation)
+		assert.Equal(t, "/bucket/mysql.zip", s3[0].BackupFile)
+	}
+
+	// generation of backup follows its copy in the storage backup refers to
+	first, err := repo.FindById(ctx, ids[0])
+	assert.Nil(t, err)
+	assert.Equal(t, 1, first.Generation)
+
+	last, err := repo.FindById(ctx, ids[2])
+	assert.Nil(t, err)
+	assert.Equal(t, 0, last.Generation)
+
+	counts, err := repo.CountRetainedCopies(ctx)
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []domain.BackupCopyCount{
+		{Rule: "mysql", StorageName: "local", Generation: 0, Count: 2},
+		{Rule: "mysql", StorageName: "local", Generation: 1, Count: 1},
+		{Rule: "mysql", StorageName: "s3", Generation: 2, Count: 1},
+	}, counts)
+}
+
+func TestBackupRepository_FindAllSuccessfulNotDeletedCopies_Order(t *testing.T) {
+	db := newTestDB(t)
+	defer db.Close()
+
+	repo := NewBackupRepository(db)
+	ctx := context.Background()
+	rule := domain.Rule{Name: "mysql", Storages: []string{"local"}}
+
+	now := time.Now().UTC().Truncate(time.Second)
+
+	// backups are ordered by moment they were created at rather than by
+	// its textual representation, backups created at once are ordered by id
+	createdAt := []time.Time{
+		now.In(time.FixedZone("UTC+5", 5*60*60)),
+		now.Add(time.Hour),
+		now.Add(time.Hour),
+	}
+
+	var ids []int64
+	for _, at := range createdAt {
+		b, err := repo.Create(ctx, domain.Backup{Rule: "mysql", ExecStatus: domain.ExecStatusSuccess, StorageName: "local", CreatedAt: at})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, b.Id)
+
+		assert.Nil(t, repo.SaveCopy(ctx, domain.BackupCopy{BackupId: b.Id, StorageName: "local", ExecStatus: domain.ExecStatusSuccess, CreatedAt: at}))
+	}
+
+	backups, err := repo.FindAllSuccessfulNotDeletedCopies(ctx, rule, "local")
+	assert.Nil(t, err)
+	if assert.Len(t, backups, 3) {
+		assert.Equal(t, ids, []int64{backups[0].Id, backups[1].Id, backups[2].Id})
+	}
+}
@@ -21,20 +21,59 @@ func NewLocalMount(root string) *LocalMount {
 }
 
 func (m *LocalMount) Transfer(backup domain.Backup) (string, error) {
-	name := fmt.Sprintf("%s_%s.zip", backup.Rule, backup.CreatedAt.UTC().Format("2006-01-02_15-04-05"))
+	name := archiveName(backup, archiveExtension(backup.TempBackupFile))
 	target := filepath.Join(m.root, name)
 
-	// Rename doesn't work across different mounts points
-	//   return target, os.Rename(backup.TempDirectory, target)
-	// For workaround see: https://github.com/rawlingsj/jx/blob/master/pkg/util/files.go
+	// Archive is copied rather than moved: it could be replicated to other
+	// storages after this one, temp directory is deallocated afterwards anyway
+	err := CopyFile(backup.TempBackupFile, target)
+	if err != nil {
+		_ = os.Remove(target)
+		return "", err
+	}
+
+	return target, nil
+}
+
+func (m *LocalMount) TransferStream(backup domain.Backup, ext string, r io.Reader) (string, error) {
+	target := filepath.Join(m.root, archiveName(backup, ext))
+
+	err := writeFile(target, r)
+	if err != nil {
+		_ = os.Remove(target)
+		return "", err
+	}
 
-	return target, RenameFile(backup.TempBackupFile, target)
+	return target, nil
 }
 
 func (m *LocalMount) Remove(backup domain.Backup) error {
 	return os.RemoveAll(backup.BackupFile)
 }
 
+func (m *LocalMount) Open(backup domain.Backup) (io.ReadCloser, error) {
+	return os.Open(backup.BackupFile)
+}
+
+func writeFile(target string, r io.Reader) (err error) {
+	out, err := os.Create(target)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if e := out.Close(); e != nil && err == nil {
+			err = e
+		}
+	}()
+
+	_, err = io.Copy(out, r)
+	if err != nil {
+		return
+	}
+
+	return out.Sync()
+}
+
 func RenameDir(src string, dst string, force bool) (err error) {
 	err = CopyDir(src, dst, force)
 	if err != nil {
@@ -0,0 +1,189 @@
+package transfer
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yurykabanov/backuper/pkg/domain"
+)
+
+// region copiesRepository
+// Keeps copies of backups in memory, the rest isn't used by transfer
+type copiesRepository struct {
+	domain.BackupRepository
+
+	copies map[int64][]domain.BackupCopy
+}
+
+func (r *copiesRepository) SaveCopy(ctx context.Context, c domain.BackupCopy) error {
+	r.copies[c.BackupId] = append(r.copies[c.BackupId], c)
+	return nil
+}
+
+func (r *copiesRepository) FindCopies(ctx context.Context, id int64) ([]domain.BackupCopy, error) {
+	return r.copies[id], nil
+}
+
+func (r *copiesRepository) Update(ctx context.Context, backup domain.Backup) error {
+	return nil
+}
+
+// endregion
+
+// region tempMountManager
+type tempMountManager struct{}
+
+func (tempMountManager) AllocateTemp() (string, error) {
+	return ioutil.TempDir("", "backuper")
+}
+
+func (tempMountManager) DeallocateTemp(dir string) error {
+	return os.RemoveAll(dir)
+}
+
+// endregion
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "backuper")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
+
+func discardLogger() *logrus.Logger {
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+
+	return logger
+}
+
+func TestLocalMount_Transfer_Replicated(t *testing.T) {
+	nas, offsite, temp := tempDir(t), tempDir(t), tempDir(t)
+	defer os.RemoveAll(nas)
+	defer os.RemoveAll(offsite)
+	defer os.RemoveAll(temp)
+
+	archiveFile := path.Join(temp, "__backup__.zip")
+
+	err := ioutil.WriteFile(archiveFile, []byte("some archive"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	manager := NewManager(map[string]domain.TransferManager{
+		"local_nas":   NewLocalMount(nas),
+		"offsite_nas": NewLocalMount(offsite),
+	})
+
+	repo := &copiesRepository{copies: make(map[int64][]domain.BackupCopy)}
+
+	svc := domain.NewBackupService(discardLogger(), repo, nil, tempMountManager{}, manager, nil, nil)
+
+	rule := domain.Rule{Name: "mysql", Storages: []string{"local_nas", "offsite_nas"}}
+	backup := domain.Backup{
+		Id:             42,
+		Rule:           "mysql",
+		ExecStatus:     domain.ExecStatusDumped,
+		TempDirectory:  temp,
+		TempBackupFile: archiveFile,
+		CreatedAt:      time.Now(),
+	}
+
+	backup, err = svc.TransferBackup(context.Background(), rule, backup)
+
+	assert.Nil(t, err)
+	assert.Equal(t, domain.ExecStatusSuccess, backup.ExecStatus)
+
+	// archive is stored in both storages
+	if assert.Len(t, repo.copies[42], 2) {
+		for _, c := range repo.copies[42] {
+			assert.Equal(t, domain.ExecStatusSuccess, c.ExecStatus, c.StorageName)
+
+			data, err := ioutil.ReadFile(c.BackupFile)
+			assert.Nil(t, err)
+			assert.Equal(t, "some archive", string(data))
+		}
+	}
+
+	// temp directory is removed along with the archive once transfer is done
+	_, err = os.Stat(temp)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestLocalMount_Open(t *testing.T) {
+	root, temp := tempDir(t), tempDir(t)
+	defer os.RemoveAll(root)
+	defer os.RemoveAll(temp)
+
+	tempBackupFile := path.Join(temp, "__backup__.zip")
+
+	err := ioutil.WriteFile(tempBackupFile, []byte("some archive"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := NewLocalMount(root)
+
+	backup := domain.Backup{Rule: "some-rule", CreatedAt: time.Now(), TempBackupFile: tempBackupFile}
+
+	backup.BackupFile, err = m.Transfer(backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := m.Open(backup)
+	if assert.Nil(t, err) {
+		data, err := ioutil.ReadAll(r)
+		assert.Nil(t, err)
+		assert.Nil(t, r.Close())
+		assert.Equal(t, "some archive", string(data))
+	}
+
+	backup.BackupFile = path.Join(root, "missing.zip")
+
+	_, err = m.Open(backup)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestLocalMount_TransferStream(t *testing.T) {
+	root := tempDir(t)
+	defer os.RemoveAll(root)
+
+	m := NewLocalMount(root)
+
+	createdAt, _ := time.Parse(time.RFC3339, "2019-01-01T02:03:04Z")
+
+	backup := domain.Backup{Rule: "some-rule", CreatedAt: createdAt}
+
+	target, err := m.TransferStream(backup, ".tar.zst", strings.NewReader("some archive"))
+
+	assert.Nil(t, err)
+	assert.Equal(t, path.Join(root, "some-rule_2019-01-01_02-03-04.tar.zst"), target)
+
+	data, err := ioutil.ReadFile(target)
+	assert.Nil(t, err)
+	assert.Equal(t, "some archive", string(data))
+
+	// partially written archive is removed when stream fails
+	backup.CreatedAt = createdAt.Add(time.Hour)
+
+	_, err = m.TransferStream(backup, ".tar.zst", io.MultiReader(strings.NewReader("some"), failingReader{}))
+
+	assert.NotNil(t, err)
+
+	files, _ := ioutil.ReadDir(root)
+	if assert.Len(t, files, 1) {
+		assert.Equal(t, "some-rule_2019-01-01_02-03-04.tar.zst", files[0].Name())
+	}
+}
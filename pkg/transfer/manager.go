@@ -2,6 +2,10 @@ package transfer
 
 import (
 	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
 
 	"github.com/yurykabanov/backuper/pkg/domain"
 )
@@ -27,9 +31,54 @@ func (m *Manager) Transfer(backup domain.Backup) (string, error) {
 	return "", ErrMountDoesNotExist
 }
 
+func (m *Manager) TransferStream(backup domain.Backup, ext string, r io.Reader) (string, error) {
+	if mount, ok := m.mounts[backup.StorageName]; ok {
+		return mount.TransferStream(backup, ext, r)
+	}
+	return "", ErrMountDoesNotExist
+}
+
 func (m *Manager) Remove(backup domain.Backup) error {
 	if mount, ok := m.mounts[backup.StorageName]; ok {
 		return mount.Remove(backup)
 	}
 	return ErrMountDoesNotExist
 }
+
+func (m *Manager) Open(backup domain.Backup) (io.ReadCloser, error) {
+	if mount, ok := m.mounts[backup.StorageName]; ok {
+		return mount.Open(backup)
+	}
+	return nil, ErrMountDoesNotExist
+}
+
+// Name of the stored archive: rule name, creation time and extension
+func archiveName(backup domain.Backup, ext string) string {
+	return fmt.Sprintf("%s_%s%s", backup.Rule, backup.CreatedAt.UTC().Format("2006-01-02_15-04-05"), ext)
+}
+
+// Full extension of the archive file (e.g. `.zip` or `.tar.zst.age` for encrypted archives)
+func archiveExtension(file string) string {
+	base := filepath.Base(file)
+	if i := strings.Index(base, "."); i >= 0 {
+		return base[i:]
+	}
+	return ".zip"
+}
+
+var contentTypes = map[string]string{
+	".zip":     "application/zip",
+	".tar":     "application/x-tar",
+	".tar.gz":  "application/gzip",
+	".tar.zst": "application/zstd",
+}
+
+// Content type of the stored archive
+func archiveContentType(name string) string {
+	for ext, contentType := range contentTypes {
+		if strings.HasSuffix(name, ext) {
+			return contentType
+		}
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,135 @@
+package transfer
+
+import (
+	"bytes"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/minio/minio-go"
+
+	"github.com/yurykabanov/backuper/pkg/domain"
+)
+
+// S3Mount stores backups in a bucket of any S3-compatible storage
+// (AWS S3, MinIO, Ceph RGW, etc.)
+type S3Mount struct {
+	client   *minio.Client
+	bucket   string
+	root     string
+	partSize int64
+}
+
+// Default size of a part used when streaming archives of unknown size
+const DefaultS3PartSize = 64 * 1024 * 1024
+
+// Minimal size of a part (except the last one) allowed by S3
+const MinS3PartSize = 5 * 1024 * 1024
+
+func NewS3Mount(client *minio.Client, bucket string, root string, partSize int64) *S3Mount {
+	if partSize <= 0 {
+		partSize = DefaultS3PartSize
+	}
+
+	return &S3Mount{
+		client:   client,
+		bucket:   bucket,
+		root:     strings.Trim(root, "/"),
+		partSize: partSize,
+	}
+}
+
+func (m *S3Mount) Transfer(backup domain.Backup) (string, error) {
+	name := archiveName(backup, archiveExtension(backup.TempBackupFile))
+	target := path.Join(m.root, name)
+
+	// Large archives are uploaded using multipart upload automatically
+	_, err := m.client.FPutObject(m.bucket, target, backup.TempBackupFile, minio.PutObjectOptions{
+		ContentType: archiveContentType(name),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return target, nil
+}
+
+// TransferStream uploads archive of unknown size using multipart upload,
+// so at most one part is kept in memory
+func (m *S3Mount) TransferStream(backup domain.Backup, ext string, r io.Reader) (string, error) {
+	name := archiveName(backup, ext)
+	target := path.Join(m.root, name)
+
+	core := minio.Core{Client: m.client}
+
+	uploadId, err := core.NewMultipartUpload(m.bucket, target, minio.PutObjectOptions{
+		ContentType: archiveContentType(name),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	parts, err := m.uploadParts(core, target, uploadId, r)
+	if err != nil {
+		_ = core.AbortMultipartUpload(m.bucket, target, uploadId)
+		return "", err
+	}
+
+	_, err = core.CompleteMultipartUpload(m.bucket, target, uploadId, parts)
+	if err != nil {
+		_ = core.AbortMultipartUpload(m.bucket, target, uploadId)
+		return "", err
+	}
+
+	return target, nil
+}
+
+func (m *S3Mount) uploadParts(core minio.Core, target, uploadId string, r io.Reader) ([]minio.CompletePart, error) {
+	var parts []minio.CompletePart
+
+	buf := make([]byte, m.partSize)
+
+	for number := 1; ; number++ {
+		n, err := io.ReadFull(r, buf)
+		if err == io.EOF && number > 1 {
+			break
+		}
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, err
+		}
+
+		// Empty archive is still uploaded as a single empty part
+		part, perr := core.PutObjectPart(m.bucket, target, uploadId, number, bytes.NewReader(buf[:n]), int64(n), "", "", nil)
+		if perr != nil {
+			return nil, perr
+		}
+
+		parts = append(parts, minio.CompletePart{PartNumber: part.PartNumber, ETag: part.ETag})
+
+		if err != nil {
+			break
+		}
+	}
+
+	return parts, nil
+}
+
+func (m *S3Mount) Remove(backup domain.Backup) error {
+	return m.client.RemoveObject(m.bucket, backup.BackupFile)
+}
+
+func (m *S3Mount) Open(backup domain.Backup) (io.ReadCloser, error) {
+	obj, err := m.client.GetObject(m.bucket, backup.BackupFile, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	// Object is fetched lazily, so make sure it does exist before returning it
+	_, err = obj.Stat()
+	if err != nil {
+		obj.Close()
+		return nil, err
+	}
+
+	return obj, nil
+}
@@ -0,0 +1,224 @@
+package transfer
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go"
+	"github.com/minio/minio-go/pkg/credentials"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/yurykabanov/backuper/pkg/domain"
+)
+
+// fakeS3 is an in-memory stand-in for S3-compatible storage (such as MinIO)
+// that supports just enough of the API to put, get and delete objects
+// including multipart uploads
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	uploads map[string][][]byte
+	aborted int
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	query := r.URL.Query()
+
+	switch {
+	case r.Method == http.MethodPost && query.Get("uploadId") != "":
+		parts, ok := s.uploads[query.Get("uploadId")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(s.uploads, query.Get("uploadId"))
+		s.objects[r.URL.Path] = bytes.Join(parts, nil)
+		writeXml(w, struct {
+			XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+			Bucket  string
+			Key     string
+			ETag    string
+		}{Bucket: "backups", Key: r.URL.Path, ETag: `"d41d8cd98f00b204e9800998ecf8427e"`})
+	case r.Method == http.MethodPost:
+		uploadId := fmt.Sprintf("upload-%d", len(s.uploads)+1)
+		s.uploads[uploadId] = nil
+		writeXml(w, struct {
+			XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+			UploadId string
+		}{UploadId: uploadId})
+	case r.Method == http.MethodPut && query.Get("uploadId") != "":
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		number, _ := strconv.Atoi(query.Get("partNumber"))
+		parts := s.uploads[query.Get("uploadId")]
+		for len(parts) < number {
+			parts = append(parts, nil)
+		}
+		parts[number-1] = data
+		s.uploads[query.Get("uploadId")] = parts
+		w.Header().Set("ETag", `"d41d8cd98f00b204e9800998ecf8427e"`)
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodDelete && query.Get("uploadId") != "":
+		delete(s.uploads, query.Get("uploadId"))
+		s.aborted++
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPut:
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		s.objects[r.URL.Path] = data
+		w.Header().Set("ETag", `"d41d8cd98f00b204e9800998ecf8427e"`)
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		data, ok := s.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("ETag", `"d41d8cd98f00b204e9800998ecf8427e"`)
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(data)
+		}
+	case r.Method == http.MethodDelete:
+		delete(s.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func writeXml(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	_ = xml.NewEncoder(w).Encode(v)
+}
+
+func newFakeS3Mount(t *testing.T, root string, partSize int64) (*S3Mount, *fakeS3, func()) {
+	fake := &fakeS3{objects: make(map[string][]byte), uploads: make(map[string][][]byte)}
+	server := httptest.NewTLSServer(fake)
+
+	u, _ := url.Parse(server.URL)
+
+	client, err := minio.NewWithOptions(u.Host, &minio.Options{
+		Creds:        credentials.NewStaticV4("access", "secret", ""),
+		Secure:       true,
+		Region:       "us-east-1",
+		BucketLookup: minio.BucketLookupPath,
+	})
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+	client.SetCustomTransport(server.Client().Transport)
+
+	return NewS3Mount(client, "backups", root, partSize), fake, server.Close
+}
+
+func TestS3Mount_TransferRemove(t *testing.T) {
+	m, fake, closeServer := newFakeS3Mount(t, "/some/prefix/", 0)
+	defer closeServer()
+
+	dir, err := ioutil.TempDir("", "backuper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tempBackupFile := path.Join(dir, "__backup__.zip")
+	err = ioutil.WriteFile(tempBackupFile, []byte("some archive"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	createdAt, _ := time.Parse(time.RFC3339, "2019-01-01T02:03:04Z")
+
+	backup := domain.Backup{
+		Rule:           "some-rule",
+		CreatedAt:      createdAt,
+		TempBackupFile: tempBackupFile,
+	}
+
+	target, err := m.Transfer(backup)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "some/prefix/some-rule_2019-01-01_02-03-04.zip", target)
+	assert.Equal(t, []byte("some archive"), fake.objects["/backups/some/prefix/some-rule_2019-01-01_02-03-04.zip"])
+
+	backup.BackupFile = target
+
+	r, err := m.Open(backup)
+	if assert.Nil(t, err) {
+		data, _ := ioutil.ReadAll(r)
+		assert.Nil(t, r.Close())
+		assert.Equal(t, []byte("some archive"), data)
+	}
+
+	err = m.Remove(backup)
+
+	assert.Nil(t, err)
+	assert.Empty(t, fake.objects)
+}
+
+func TestS3Mount_Open_NotFound(t *testing.T) {
+	m, _, closeServer := newFakeS3Mount(t, "", 0)
+	defer closeServer()
+
+	_, err := m.Open(domain.Backup{BackupFile: "missing.zip"})
+
+	assert.NotNil(t, err)
+}
+
+func TestS3Mount_TransferStream(t *testing.T) {
+	m, fake, closeServer := newFakeS3Mount(t, "backups", 4)
+	defer closeServer()
+
+	createdAt, _ := time.Parse(time.RFC3339, "2019-01-01T02:03:04Z")
+	backup := domain.Backup{Rule: "some-rule", CreatedAt: createdAt}
+
+	target, err := m.TransferStream(backup, ".tar.zst", bytes.NewReader([]byte("some archive")))
+
+	assert.Nil(t, err)
+	assert.Equal(t, "backups/some-rule_2019-01-01_02-03-04.tar.zst", target)
+	assert.Equal(t, []byte("some archive"), fake.objects["/backups/backups/some-rule_2019-01-01_02-03-04.tar.zst"])
+	assert.Empty(t, fake.uploads)
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, fmt.Errorf("unable to read")
+}
+
+func TestS3Mount_TransferStream_Abort(t *testing.T) {
+	m, fake, closeServer := newFakeS3Mount(t, "", 4)
+	defer closeServer()
+
+	_, err := m.TransferStream(domain.Backup{Rule: "some-rule"}, ".zip", failingReader{})
+
+	assert.NotNil(t, err)
+	assert.Empty(t, fake.objects)
+	assert.Empty(t, fake.uploads)
+	assert.Equal(t, 1, fake.aborted)
+}
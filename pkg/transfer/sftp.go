@@ -0,0 +1,155 @@
+package transfer
+
+import (
+	"io"
+	"os"
+	"path"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+
+	"github.com/yurykabanov/backuper/pkg/domain"
+)
+
+// SFTPMount stores backups on a remote host accessible via SSH (SFTP subsystem)
+type SFTPMount struct {
+	addr   string
+	config *ssh.ClientConfig
+	root   string
+}
+
+func NewSFTPMount(addr string, config *ssh.ClientConfig, root string) *SFTPMount {
+	return &SFTPMount{
+		addr:   addr,
+		config: config,
+		root:   root,
+	}
+}
+
+func (m *SFTPMount) Transfer(backup domain.Backup) (string, error) {
+	f, err := os.Open(backup.TempBackupFile)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	return m.TransferStream(backup, archiveExtension(backup.TempBackupFile), f)
+}
+
+func (m *SFTPMount) TransferStream(backup domain.Backup, ext string, r io.Reader) (string, error) {
+	name := archiveName(backup, ext)
+	target := path.Join(m.root, name)
+
+	// Archive is uploaded under temporary name and renamed afterwards,
+	// so incomplete uploads never look like successful backups
+	temp := path.Join(m.root, "."+name+".part")
+
+	conn, client, err := m.connect()
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	defer client.Close()
+
+	err = client.MkdirAll(m.root)
+	if err != nil {
+		return "", err
+	}
+
+	err = m.upload(client, r, temp)
+	if err != nil {
+		_ = client.Remove(temp)
+		return "", err
+	}
+
+	// Not every server supports `posix-rename@openssh.com` extension
+	if err := client.PosixRename(temp, target); err != nil {
+		err = client.Rename(temp, target)
+		if err != nil {
+			_ = client.Remove(temp)
+			return "", err
+		}
+	}
+
+	return target, nil
+}
+
+func (m *SFTPMount) Remove(backup domain.Backup) error {
+	conn, client, err := m.connect()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	defer client.Close()
+
+	err = client.Remove(backup.BackupFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+func (m *SFTPMount) Open(backup domain.Backup) (io.ReadCloser, error) {
+	conn, client, err := m.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := client.Open(backup.BackupFile)
+	if err != nil {
+		client.Close()
+		conn.Close()
+		return nil, err
+	}
+
+	return &sftpFile{File: f, client: client, conn: conn}, nil
+}
+
+// Remote file which closes underlying connection on close
+type sftpFile struct {
+	*sftp.File
+
+	client *sftp.Client
+	conn   *ssh.Client
+}
+
+func (f *sftpFile) Close() error {
+	err := f.File.Close()
+
+	f.client.Close()
+	f.conn.Close()
+
+	return err
+}
+
+func (m *SFTPMount) connect() (*ssh.Client, *sftp.Client, error) {
+	conn, err := ssh.Dial("tcp", m.addr, m.config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	return conn, client, nil
+}
+
+func (m *SFTPMount) upload(client *sftp.Client, r io.Reader, dst string) (err error) {
+	out, err := client.Create(dst)
+	if err != nil {
+		return
+	}
+	defer func() {
+		if e := out.Close(); e != nil && err == nil {
+			err = e
+		}
+	}()
+
+	_, err = io.Copy(out, r)
+
+	return
+}
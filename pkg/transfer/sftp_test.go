@@ -0,0 +1,264 @@
+package transfer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+
+	"github.com/yurykabanov/backuper/pkg/domain"
+)
+
+// startSSHServer starts in-process SSH server with SFTP subsystem serving
+// local filesystem and returns its address and host key
+func startSSHServer(t *testing.T, user, password string) (string, ssh.PublicKey, func()) {
+	return serveSSH(t, &ssh.ServerConfig{
+		PasswordCallback: func(c ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
+			if c.User() == user && string(pass) == password {
+				return nil, nil
+			}
+			return nil, os.ErrPermission
+		},
+	})
+}
+
+// startSSHKeyServer is the same as startSSHServer but accepts only given public key
+func startSSHKeyServer(t *testing.T, user string, key ssh.PublicKey) (string, ssh.PublicKey, func()) {
+	return serveSSH(t, &ssh.ServerConfig{
+		PublicKeyCallback: func(c ssh.ConnMetadata, k ssh.PublicKey) (*ssh.Permissions, error) {
+			if c.User() == user && bytes.Equal(k.Marshal(), key.Marshal()) {
+				return nil, nil
+			}
+			return nil, os.ErrPermission
+		},
+	})
+}
+
+func serveSSH(t *testing.T, config *ssh.ServerConfig) (string, ssh.PublicKey, func()) {
+	hostKey := generateSigner(t)
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go serveSSHConn(conn, config)
+		}
+	}()
+
+	return listener.Addr().String(), hostKey.PublicKey(), func() { listener.Close() }
+}
+
+func generateSigner(t *testing.T) ssh.Signer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signer
+}
+
+func serveSSHConn(conn net.Conn, config *ssh.ServerConfig) {
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+
+		go func(in <-chan *ssh.Request) {
+			for req := range in {
+				ok := req.Type == "subsystem" && string(req.Payload[4:]) == "sftp"
+				_ = req.Reply(ok, nil)
+			}
+		}(requests)
+
+		go func() {
+			defer channel.Close()
+
+			server, err := sftp.NewServer(channel)
+			if err != nil {
+				return
+			}
+
+			_ = server.Serve()
+		}()
+	}
+}
+
+func newTestSFTPMount(t *testing.T, root, password string) (*SFTPMount, func()) {
+	addr, hostKey, stop := startSSHServer(t, "backuper", "secret")
+
+	return newSFTPMountWithAuth(t, root, addr, hostKey, ssh.Password(password)), stop
+}
+
+func newSFTPMountWithAuth(t *testing.T, root, addr string, hostKey ssh.PublicKey, auth ssh.AuthMethod) *SFTPMount {
+	knownHostsFile := path.Join(root, "known_hosts")
+	err := ioutil.WriteFile(knownHostsFile, []byte(knownhosts.Line([]string{addr}, hostKey)+"\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hostKeyCallback, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &ssh.ClientConfig{
+		User:            "backuper",
+		Auth:            []ssh.AuthMethod{auth},
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         5 * time.Second,
+	}
+
+	return NewSFTPMount(addr, config, path.Join(root, "remote", "backups"))
+}
+
+func TestSFTPMount_TransferRemove(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backuper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	m, stop := newTestSFTPMount(t, dir, "secret")
+	defer stop()
+
+	tempBackupFile := path.Join(dir, "__backup__.zip")
+	err = ioutil.WriteFile(tempBackupFile, []byte("some archive"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	createdAt, _ := time.Parse(time.RFC3339, "2019-01-01T02:03:04Z")
+
+	backup := domain.Backup{
+		Rule:           "some-rule",
+		CreatedAt:      createdAt,
+		TempBackupFile: tempBackupFile,
+	}
+
+	target, err := m.Transfer(backup)
+
+	assert.Nil(t, err)
+	assert.Equal(t, path.Join(dir, "remote", "backups", "some-rule_2019-01-01_02-03-04.zip"), target)
+
+	data, err := ioutil.ReadFile(target)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("some archive"), data)
+
+	// temporary file must be renamed
+	files, _ := ioutil.ReadDir(path.Join(dir, "remote", "backups"))
+	assert.Len(t, files, 1)
+
+	backup.BackupFile = target
+
+	r, err := m.Open(backup)
+	if assert.Nil(t, err) {
+		data, _ := ioutil.ReadAll(r)
+		assert.Nil(t, r.Close())
+		assert.Equal(t, []byte("some archive"), data)
+	}
+
+	err = m.Remove(backup)
+
+	assert.Nil(t, err)
+	_, err = os.Stat(target)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestSFTPMount_Transfer_AuthFailure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backuper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	m, stop := newTestSFTPMount(t, dir, "wrong")
+	defer stop()
+
+	_, err = m.Transfer(domain.Backup{Rule: "some-rule", TempBackupFile: path.Join(dir, "missing.zip")})
+
+	assert.NotNil(t, err)
+}
+
+func TestSFTPMount_Transfer_PrivateKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backuper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// private key is read the same way as `private_key` file of sftp storage
+	signer, err := ssh.ParsePrivateKey(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr, hostKey, stop := startSSHKeyServer(t, "backuper", signer.PublicKey())
+	defer stop()
+
+	tempBackupFile := path.Join(dir, "__backup__.zip")
+	err = ioutil.WriteFile(tempBackupFile, []byte("some archive"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backup := domain.Backup{Rule: "some-rule", CreatedAt: time.Now(), TempBackupFile: tempBackupFile}
+
+	// server doesn't accept passwords
+	_, err = newSFTPMountWithAuth(t, dir, addr, hostKey, ssh.Password("secret")).Transfer(backup)
+	assert.NotNil(t, err)
+
+	// nor keys other than authorized one
+	_, err = newSFTPMountWithAuth(t, dir, addr, hostKey, ssh.PublicKeys(generateSigner(t))).Transfer(backup)
+	assert.NotNil(t, err)
+
+	target, err := newSFTPMountWithAuth(t, dir, addr, hostKey, ssh.PublicKeys(signer)).Transfer(backup)
+	assert.Nil(t, err)
+
+	data, err := ioutil.ReadFile(target)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("some archive"), data)
+}
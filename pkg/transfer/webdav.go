@@ -0,0 +1,180 @@
+package transfer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/yurykabanov/backuper/pkg/domain"
+)
+
+// WebDAVMount stores backups on WebDAV server (Nextcloud, ownCloud, etc.)
+type WebDAVMount struct {
+	client   *http.Client
+	baseUrl  string
+	username string
+	password string
+	root     string
+}
+
+func NewWebDAVMount(client *http.Client, baseUrl, username, password, root string) *WebDAVMount {
+	return &WebDAVMount{
+		client:   client,
+		baseUrl:  strings.TrimRight(baseUrl, "/"),
+		username: username,
+		password: password,
+		root:     path.Join("/", root),
+	}
+}
+
+func (m *WebDAVMount) Transfer(backup domain.Backup) (string, error) {
+	f, err := os.Open(backup.TempBackupFile)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+
+	return m.put(archiveName(backup, archiveExtension(backup.TempBackupFile)), f, stat.Size())
+}
+
+// TransferStream uploads archive of unknown size using chunked transfer encoding
+func (m *WebDAVMount) TransferStream(backup domain.Backup, ext string, r io.Reader) (string, error) {
+	return m.put(archiveName(backup, ext), r, -1)
+}
+
+func (m *WebDAVMount) put(name string, r io.Reader, size int64) (string, error) {
+	target := path.Join(m.root, name)
+
+	err := m.makeCollections(m.root)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := m.newRequest(context.TODO(), http.MethodPut, target, r)
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", archiveContentType(name))
+
+	err = m.do(req, http.StatusCreated, http.StatusNoContent, http.StatusOK)
+	if err != nil {
+		return "", err
+	}
+
+	return target, nil
+}
+
+func (m *WebDAVMount) Remove(backup domain.Backup) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	req, err := m.newRequest(ctx, "DELETE", backup.BackupFile, nil)
+	if err != nil {
+		return err
+	}
+
+	return m.do(req, http.StatusNoContent, http.StatusOK, http.StatusNotFound)
+}
+
+func (m *WebDAVMount) Open(backup domain.Backup) (io.ReadCloser, error) {
+	req, err := m.newRequest(context.TODO(), http.MethodGet, backup.BackupFile, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := m.send(req, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+// Creates collection (directory) with all its parents if they don't exist
+func (m *WebDAVMount) makeCollections(dir string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	current := "/"
+
+	for _, segment := range strings.Split(strings.Trim(dir, "/"), "/") {
+		if segment == "" {
+			continue
+		}
+
+		current = path.Join(current, segment)
+
+		req, err := m.newRequest(ctx, "MKCOL", current+"/", nil)
+		if err != nil {
+			return err
+		}
+
+		// Server responds with '405 Method Not Allowed' if collection already exists
+		err = m.do(req, http.StatusCreated, http.StatusMethodNotAllowed)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *WebDAVMount) newRequest(ctx context.Context, method, p string, body io.Reader) (*http.Request, error) {
+	u := m.baseUrl + (&url.URL{Path: p}).EscapedPath()
+
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if m.username != "" || m.password != "" {
+		req.SetBasicAuth(m.username, m.password)
+	}
+
+	return req.WithContext(ctx), nil
+}
+
+// Sends request and discards response body
+func (m *WebDAVMount) do(req *http.Request, expectedStatuses ...int) error {
+	resp, err := m.send(req, expectedStatuses...)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	_, err = io.Copy(ioutil.Discard, resp.Body)
+
+	return err
+}
+
+// Sends request and returns response with unread body if response status is expected
+func (m *WebDAVMount) send(req *http.Request, expectedStatuses ...int) (*http.Response, error) {
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, status := range expectedStatuses {
+		if resp.StatusCode == status {
+			return resp, nil
+		}
+	}
+
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	return nil, fmt.Errorf("webdav: unexpected response status '%s' for %s %s", resp.Status, req.Method, req.URL.Path)
+}
@@ -0,0 +1,131 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/webdav"
+
+	"github.com/yurykabanov/backuper/pkg/domain"
+)
+
+func newTestWebDAVServer(fs webdav.FileSystem) *httptest.Server {
+	h := &webdav.Handler{
+		FileSystem: fs,
+		LockSystem: webdav.NewMemLS(),
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "backuper" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		h.ServeHTTP(w, r)
+	}))
+}
+
+func TestWebDAVMount_TransferRemove(t *testing.T) {
+	fs := webdav.NewMemFS()
+	server := newTestWebDAVServer(fs)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "backuper")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tempBackupFile := path.Join(dir, "__backup__.zip")
+	err = ioutil.WriteFile(tempBackupFile, []byte("some archive"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	createdAt, _ := time.Parse(time.RFC3339, "2019-01-01T02:03:04Z")
+
+	backup := domain.Backup{
+		Rule:           "some-rule",
+		CreatedAt:      createdAt,
+		TempBackupFile: tempBackupFile,
+	}
+
+	m := NewWebDAVMount(server.Client(), server.URL+"/", "backuper", "secret", "some/nested dir")
+
+	target, err := m.Transfer(backup)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "/some/nested dir/some-rule_2019-01-01_02-03-04.zip", target)
+
+	f, err := fs.OpenFile(context.Background(), target, os.O_RDONLY, 0)
+	if assert.Nil(t, err) {
+		data, _ := ioutil.ReadAll(f)
+		f.Close()
+		assert.Equal(t, []byte("some archive"), data)
+	}
+
+	// existing collections must not break subsequent uploads
+	backup.CreatedAt = createdAt.Add(time.Hour)
+	_, err = m.Transfer(backup)
+	assert.Nil(t, err)
+
+	backup.BackupFile = target
+
+	r, err := m.Open(backup)
+	if assert.Nil(t, err) {
+		data, _ := ioutil.ReadAll(r)
+		assert.Nil(t, r.Close())
+		assert.Equal(t, []byte("some archive"), data)
+	}
+
+	err = m.Remove(backup)
+
+	assert.Nil(t, err)
+	_, err = fs.Stat(context.Background(), target)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestWebDAVMount_TransferStream(t *testing.T) {
+	fs := webdav.NewMemFS()
+	server := newTestWebDAVServer(fs)
+	defer server.Close()
+
+	createdAt, _ := time.Parse(time.RFC3339, "2019-01-01T02:03:04Z")
+	backup := domain.Backup{Rule: "some-rule", CreatedAt: createdAt}
+
+	m := NewWebDAVMount(server.Client(), server.URL, "backuper", "secret", "/backups")
+
+	// wrapped to hide the size of the stream
+	target, err := m.TransferStream(backup, ".tar.gz", struct{ io.Reader }{bytes.NewReader([]byte("some archive"))})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "/backups/some-rule_2019-01-01_02-03-04.tar.gz", target)
+
+	f, err := fs.OpenFile(context.Background(), target, os.O_RDONLY, 0)
+	if assert.Nil(t, err) {
+		data, _ := ioutil.ReadAll(f)
+		f.Close()
+		assert.Equal(t, []byte("some archive"), data)
+	}
+}
+
+func TestWebDAVMount_Transfer_Unauthorized(t *testing.T) {
+	server := newTestWebDAVServer(webdav.NewMemFS())
+	defer server.Close()
+
+	m := NewWebDAVMount(server.Client(), server.URL, "backuper", "wrong", "/backups")
+
+	_, err := m.Transfer(domain.Backup{Rule: "some-rule"})
+
+	assert.NotNil(t, err)
+}
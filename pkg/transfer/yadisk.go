@@ -3,6 +3,8 @@ package transfer
 import (
 	"context"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"path"
 	"time"
@@ -25,24 +27,27 @@ func NewYaDiskMount(client *yadisk.Client, root string) *YaDiskMount {
 }
 
 func (m *YaDiskMount) Transfer(backup domain.Backup) (string, error) {
-	name := fmt.Sprintf("%s_%s.zip", backup.Rule, backup.CreatedAt.UTC().Format("2006-01-02_15-04-05"))
-	target := path.Join(m.root, name)
+	f, err := os.Open(backup.TempBackupFile)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	return m.TransferStream(backup, archiveExtension(backup.TempBackupFile), f)
+}
+
+func (m *YaDiskMount) TransferStream(backup domain.Backup, ext string, r io.Reader) (string, error) {
+	target := path.Join(m.root, archiveName(backup, ext))
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 
 	link, err := m.client.RequestUploadLink(ctx, target, false)
-	if err != nil {
-		return "", err
-	}
 	cancel()
-
-	f, err := os.Open(backup.TempBackupFile)
 	if err != nil {
 		return "", err
 	}
-	defer f.Close()
 
-	_, err = m.client.Upload(context.TODO(), link, f)
+	_, err = m.client.Upload(context.TODO(), link, r)
 	if err != nil {
 		return "", err
 	}
@@ -57,3 +62,26 @@ func (m *YaDiskMount) Remove(backup domain.Backup) error {
 	_, _, err := m.client.Delete(ctx, backup.BackupFile, true)
 	return err
 }
+
+func (m *YaDiskMount) Open(backup domain.Backup) (io.ReadCloser, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+
+	link, err := m.client.RequestDownloadLink(ctx, backup.BackupFile)
+	cancel()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := m.client.Download(context.TODO(), link)
+	if err != nil {
+		return nil, err
+	}
+
+	// Download doesn't check response status by itself
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("yadisk: unexpected response status '%s' while downloading %s", resp.Status, backup.BackupFile)
+	}
+
+	return resp.Body, nil
+}